@@ -0,0 +1,14 @@
+package assets
+
+import (
+	_ "embed"
+
+	"fyne.io/fyne/v2"
+)
+
+//go:embed icons/icon.png
+var iconPNG []byte
+
+// Icon is the application icon, built into the binary so it doesn't
+// depend on the working directory
+var Icon = fyne.NewStaticResource("icon.png", iconPNG)
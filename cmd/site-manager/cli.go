@@ -0,0 +1,539 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"shinobi-webserver/internal/bundle"
+	"shinobi-webserver/internal/checker"
+	"shinobi-webserver/internal/config"
+	"shinobi-webserver/internal/export"
+	"shinobi-webserver/internal/server"
+	"shinobi-webserver/internal/snapshot"
+)
+
+const usage = `Usage: site-manager [--config path] [--workspace name] [command] [options]
+
+Without a command the GUI is started; with --background it starts hidden in
+the system tray. Only one GUI runs per config file: launching it again brings
+the window to the front, and these commands are handed to it:
+
+  open <folder>                       Add a folder as a site
+  start <site>                        Start a site
+  stop <site>                         Stop a site
+
+Commands:
+  export [-o target] [-json] [-force] <site>
+                                      Render a site to a folder or .zip
+                                      (-force writes into a folder with files
+                                      or replaces an existing .zip)
+  check [-url base] [-max-size KB] [-text] <site>
+                                      Check a site for broken links and assets
+  bundle [-o file] [-content] [site...]
+                                      Save site definitions (all by default) as
+                                      .json, .yaml or .zip (-content adds files)
+  import [-on-conflict rename|skip|replace] [-json] <bundle|folder>
+                                      Add sites from a bundle, or a folder from
+                                      its shinobi.json or index.html
+  snapshot [-note text] <site>        Save the site's folder and settings
+  snapshot -list|-diff id|-restore id|-delete id [-json] <site>
+                                      Manage the site's snapshots
+  serve [site...]                     Serve the given sites, or those set to
+                                      start on launch, without the GUI
+  systemd [-o dir] [-manager] [-install] [-enable] [site...]
+                                      Write systemd user units running serve,
+                                      one per site (all by default)
+  systemd -verify <unit...>           Check unit files offline
+  workspaces                          List workspaces and their sites
+  help                                Show this help
+
+A <site> is a site ID, as listed by workspaces, or a site name in the
+selected workspace.
+
+Global options:
+`
+
+func runCommand(args []string) int {
+	switch args[0] {
+	case "export":
+		return cmdExport(args[1:])
+	case "check":
+		return cmdCheck(args[1:])
+	case "bundle":
+		return cmdBundle(args[1:])
+	case "import":
+		return cmdImport(args[1:])
+	case "snapshot":
+		return cmdSnapshot(args[1:])
+	case "serve":
+		return cmdServe(args[1:])
+	case "systemd":
+		return cmdSystemd(args[1:])
+	case "workspaces":
+		return cmdWorkspaces(args[1:])
+	case "help":
+		flag.Usage()
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+	return 2
+}
+
+func cmdExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	target := fs.String("o", "", "output folder, or a file ending in .zip (default <site>-export)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	force := fs.Bool("force", false, "write into an output folder that already has files, or replace an existing .zip")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "export: expected exactly one site name")
+		return 2
+	}
+
+	cfg, site, err := loadSite(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+
+	if *target == "" {
+		*target = site.Name + "-export"
+	}
+
+	srv, err := server.NewForSite(site, cfg.Settings().NetworkProfiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+
+	report, err := export.Export(srv.Handler(), site.FolderPath(), *target, *force)
+	if errors.Is(err, export.ErrTargetNotEmpty) {
+		fmt.Fprintf(os.Stderr, "export: %v; use -force to write over it\n", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		printJSON(report)
+	} else {
+		fmt.Printf("Exported %d files to %s\n", len(report.Files), report.Target)
+		if len(report.Redirects) > 0 {
+			fmt.Printf("Wrote %d redirects to %s\n", len(report.Redirects), export.RedirectsFile)
+		}
+		for _, l := range report.BrokenLinks {
+			fmt.Printf("Broken link on %s: %s (%d)\n", l.Page, l.Link, l.Status)
+		}
+		for _, f := range report.Errors {
+			fmt.Printf("Failed to export %s: %s\n", f.URL, f.Error)
+		}
+	}
+
+	if len(report.BrokenLinks) > 0 || len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func cmdCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	baseURL := fs.String("url", "", "URL of the running site (default: serve it temporarily)")
+	maxSize := fs.Int64("max-size", checker.DefaultMaxAssetSize>>10, "report assets larger than this many KB")
+	asText := fs.Bool("text", false, "print a readable summary instead of JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "check: expected exactly one site name")
+		return 2
+	}
+
+	cfg, site, err := loadSite(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check: %v\n", err)
+		return 1
+	}
+
+	if *baseURL == "" {
+		srv, err := server.NewForSite(site, cfg.Settings().NetworkProfiles)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "check: %v\n", err)
+			return 1
+		}
+		ts := httptest.NewServer(srv.Handler())
+		defer ts.Close()
+		*baseURL = ts.URL
+	}
+
+	report, err := checker.Check(*baseURL, site.FolderPath(), site.EntryFile, checker.Options{
+		MaxAssetSize: *maxSize << 10,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check: %v\n", err)
+		return 1
+	}
+
+	if *asText {
+		fmt.Printf("Checked %d URLs in %v\n", report.Checked, report.Duration.Round(time.Millisecond))
+		for _, issue := range report.Issues {
+			line := fmt.Sprintf("[%s] %s %s", issue.Severity, issue.Kind, issue.URL)
+			if issue.Source != "" {
+				line += " (from " + issue.Source + ")"
+			}
+			if issue.Detail != "" {
+				line += ": " + issue.Detail
+			}
+			fmt.Println(line)
+		}
+	} else {
+		printJSON(report)
+	}
+
+	if report.Failed() {
+		return 1
+	}
+	return 0
+}
+
+func cmdBundle(args []string) int {
+	fs := flag.NewFlagSet("bundle", flag.ContinueOnError)
+	target := fs.String("o", "", "output file ending in .json, .yaml or .zip (default <workspace>-sites.json)")
+	withContent := fs.Bool("content", false, "include the site folders (.zip only)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bundle: %v\n", err)
+		return 1
+	}
+
+	sites := cfg.Sites()
+	if fs.NArg() > 0 {
+		sites = nil
+		for _, ref := range fs.Args() {
+			site, err := findSite(cfg, ref)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "bundle: %v\n", err)
+				return 1
+			}
+			sites = append(sites, *site)
+		}
+	}
+
+	if *target == "" {
+		*target = cfg.Workspace().Name + "-sites.json"
+		if *withContent {
+			*target = cfg.Workspace().Name + "-sites.zip"
+		}
+	}
+
+	if err := bundle.Write(bundle.New(sites), *target, *withContent); err != nil {
+		fmt.Fprintf(os.Stderr, "bundle: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Bundled %d sites to %s\n", len(sites), *target)
+	return 0
+}
+
+func cmdImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	onConflict := fs.String("on-conflict", string(bundle.ConflictRename), "what to do with taken site names: rename, skip or replace")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "import: expected exactly one bundle file or folder")
+		return 2
+	}
+
+	conflict := bundle.Conflict(*onConflict)
+	switch conflict {
+	case bundle.ConflictRename, bundle.ConflictSkip, bundle.ConflictReplace:
+	default:
+		fmt.Fprintf(os.Stderr, "import: unknown -on-conflict %q\n", *onConflict)
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+
+	source := fs.Arg(0)
+	var result *bundle.Result
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		outcome, err := bundle.OpenFolder(cfg, source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import: %v\n", err)
+			return 1
+		}
+		result = &bundle.Result{Sites: []bundle.Outcome{outcome}}
+	} else {
+		b, err := bundle.Read(source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import: %v\n", err)
+			return 1
+		}
+		opts := bundle.Options{OnConflict: conflict}
+		if cfg.Settings().AutoSnapshot {
+			opts.BeforeReplace = func(id string) error {
+				if site := cfg.GetSite(id); site != nil {
+					if _, err := snapshot.Create(*site, cfg.Workspace().Name, "Before import replaced it", true); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		}
+		result = bundle.Import(cfg, b, opts)
+	}
+
+	if *asJSON {
+		printJSON(result)
+	} else {
+		for _, o := range result.Sites {
+			line := fmt.Sprintf("%s: %s", o.Name, o.Action)
+			if o.OriginalName != "" {
+				line += fmt.Sprintf(" (renamed from %s)", o.OriginalName)
+			}
+			if o.OriginalPort != 0 {
+				line += fmt.Sprintf(" (port %d taken, using %d)", o.OriginalPort, o.Port)
+			}
+			if o.Error != "" {
+				line += ": " + o.Error
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if result.Failed() > 0 {
+		return 1
+	}
+	return 0
+}
+
+func cmdSnapshot(args []string) int {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	note := fs.String("note", "", "describe the snapshot")
+	list := fs.Bool("list", false, "list the site's snapshots")
+	diff := fs.String("diff", "", "show what changed since the snapshot with this ID")
+	restore := fs.String("restore", "", "restore the snapshot with this ID")
+	remove := fs.String("delete", "", "delete the snapshot with this ID")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "snapshot: expected exactly one site name")
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "snapshot: %v\n", err)
+		return 1
+	}
+	site, err := findSite(cfg, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "snapshot: %v\n", err)
+		return 1
+	}
+	name := site.Name
+	workspace := cfg.WorkspaceOf(site.ID)
+
+	get := func(id string) *snapshot.Snapshot {
+		snap, err := snapshot.Get(workspace, *site, id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "snapshot: %v\n", err)
+			return nil
+		}
+		return snap
+	}
+
+	switch {
+	case *list:
+		snaps, err := snapshot.List(workspace, *site)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "snapshot: %v\n", err)
+			return 1
+		}
+		if *asJSON {
+			if snaps == nil {
+				snaps = []*snapshot.Snapshot{}
+			}
+			printJSON(snaps)
+			return 0
+		}
+		for _, snap := range snaps {
+			line := fmt.Sprintf("%s  %s  %d files, %d bytes", snap.ID, snap.Created.Local().Format("2006-01-02 15:04:05"), snap.Files, snap.Size)
+			if snap.Note != "" {
+				line += "  " + snap.Note
+			}
+			fmt.Println(line)
+		}
+
+	case *diff != "":
+		snap := get(*diff)
+		if snap == nil {
+			return 1
+		}
+		d, err := snap.Diff(site.FolderPath())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "snapshot: %v\n", err)
+			return 1
+		}
+		if *asJSON {
+			printJSON(d)
+			return 0
+		}
+		for _, f := range d.Added {
+			fmt.Println("+ " + f)
+		}
+		for _, f := range d.Modified {
+			fmt.Println("~ " + f)
+		}
+		for _, f := range d.Removed {
+			fmt.Println("- " + f)
+		}
+
+	case *restore != "":
+		snap := get(*restore)
+		if snap == nil {
+			return 1
+		}
+		if _, err := snapshot.Create(*site, workspace, "Before restoring "+snap.ID, true); err != nil {
+			fmt.Fprintf(os.Stderr, "snapshot: couldn't save the current state: %v\n", err)
+			return 1
+		}
+		if err := snap.Restore(cfg, site.ID); err != nil {
+			fmt.Fprintf(os.Stderr, "snapshot: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Restored %s to %s\n", name, snap.ID)
+
+	case *remove != "":
+		snap := get(*remove)
+		if snap == nil {
+			return 1
+		}
+		if err := snap.Remove(); err != nil {
+			fmt.Fprintf(os.Stderr, "snapshot: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Deleted snapshot %s\n", snap.ID)
+
+	default:
+		snap, err := snapshot.Create(*site, workspace, *note, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "snapshot: %v\n", err)
+			return 1
+		}
+		if *asJSON {
+			printJSON(snap)
+			return 0
+		}
+		fmt.Printf("Saved snapshot %s of %s (%d files, %d bytes compressed)\n", snap.ID, name, snap.Files, snap.Archive)
+	}
+	return 0
+}
+
+func cmdWorkspaces(args []string) int {
+	fs := flag.NewFlagSet("workspaces", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the workspaces as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "workspaces: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		printJSON(cfg.AllWorkspaces())
+		return 0
+	}
+
+	current := cfg.Workspace().Name
+	for _, ws := range cfg.AllWorkspaces() {
+		marker := " "
+		if ws.Name == current {
+			marker = "*"
+		}
+		fmt.Printf("%s %s (ports %d-%d, %s)\n", marker, ws.Name, ws.AutoPortMin, ws.AutoPortMax, ws.Base())
+		for _, site := range ws.Sites {
+			fmt.Printf("    %s  %s  :%d  %s\n", site.ID, site.Name, site.Port, site.FolderPath())
+		}
+	}
+	return 0
+}
+
+// loadConfig loads the config and selects the --workspace one
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		var invalid *config.ValidationError
+		if !errors.As(err, &invalid) {
+			return nil, fmt.Errorf("failed to load config: %v", err)
+		}
+		// Report problems but carry on, they rarely concern the command
+		for _, p := range invalid.Problems {
+			fmt.Fprintf(os.Stderr, "config %s: %s\n", p.Severity, p)
+		}
+	}
+
+	if workspace != "" {
+		if err := cfg.UseWorkspace(workspace); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+func loadSite(ref string) (*config.Config, *config.Site, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	site, err := findSite(cfg, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, site, nil
+}
+
+// findSite looks a site up by ID, then by name in the selected workspace
+func findSite(cfg *config.Config, ref string) (*config.Site, error) {
+	if site := cfg.GetSite(ref); site != nil {
+		return site, nil
+	}
+	if site := cfg.FindSite(ref); site != nil {
+		return site, nil
+	}
+	return nil, fmt.Errorf("no site with ID or name %q in workspace %q", ref, cfg.Workspace().Name)
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
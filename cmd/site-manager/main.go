@@ -1,21 +1,111 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
 	"shinobi-webserver/internal/config"
+	"shinobi-webserver/internal/instance"
 	"shinobi-webserver/internal/ui"
 
 	"fyne.io/fyne/v2/app"
 )
 
+// Workspace selected with --workspace, empty for the last one used
+var workspace string
+
 func main() {
+	configPath := flag.String("config", "", "path to the config file (default "+config.DefaultPath()+")")
+	flag.StringVar(&workspace, "workspace", "", "workspace to use (default the last one used)")
+	background := flag.Bool("background", false, "start hidden in the system tray")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *configPath != "" {
+		config.SetPath(*configPath)
+	}
+
+	// Subcommands run without the GUI, except those carried out by it
+	action, actionArgs := instance.ActionShow, []string(nil)
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case instance.ActionOpen, instance.ActionStart, instance.ActionStop:
+			if flag.NArg() != 2 {
+				fmt.Fprintf(os.Stderr, "%s: expected exactly one argument\n", flag.Arg(0))
+				os.Exit(2)
+			}
+			action, actionArgs = flag.Arg(0), flag.Args()[1:]
+			if action == instance.ActionOpen {
+				// The running instance may have another working directory
+				if abs, err := filepath.Abs(actionArgs[0]); err == nil {
+					actionArgs[0] = abs
+				}
+			}
+		default:
+			os.Exit(runCommand(flag.Args()))
+		}
+	}
+
+	// One GUI per config file; later launches hand over to it
+	inst, err := instance.Acquire()
+	switch {
+	case errors.Is(err, instance.ErrRunning):
+		if *background && action == instance.ActionShow {
+			return
+		}
+		if action == instance.ActionStart || action == instance.ActionStop {
+			// Names are looked up in the --workspace given here
+			if cfg, err := loadConfig(); err == nil {
+				if site, err := findSite(cfg, actionArgs[0]); err == nil {
+					actionArgs[0] = site.ID
+				}
+			}
+		}
+		if err := instance.Send(action, actionArgs...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "not checking for other instances: %v\n", err)
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
-		cfg = config.NewDefault()
+		var invalid *config.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			// Keep what was loaded, the UI offers repairs
+		case os.IsNotExist(err):
+			cfg, err = config.NewDefault(), nil
+		default:
+			// Unreadable; start empty without touching the file
+			cfg = config.NewDefault()
+		}
+	}
+
+	if workspace != "" {
+		if werr := cfg.UseWorkspace(workspace); werr != nil {
+			fmt.Fprintln(os.Stderr, werr)
+			os.Exit(2)
+		}
 	}
 
 	// Create Fyne app
 	a := app.New()
 
 	// Start UI
-	ui.StartWithApp(a, cfg)
+	ui.StartWithApp(a, cfg, ui.Options{
+		LoadError:  err,
+		Background: *background,
+		Instance:   inst,
+		Action:     action,
+		ActionArgs: actionArgs,
+	})
 }
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"shinobi-webserver/internal/config"
+	"shinobi-webserver/internal/launch"
+	"shinobi-webserver/internal/server"
+	"shinobi-webserver/internal/systemd"
+)
+
+// cmdServe runs sites without the GUI until interrupted. It exits with an
+// error when a server stops on its own, so a service manager restarts it.
+func cmdServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "serve: %v\n", err)
+		return 1
+	}
+
+	var servers []*server.Server
+	var names []string
+	start := func(site config.Site) error {
+		srv, err := server.NewForSite(&site, cfg.Settings().NetworkProfiles)
+		if err != nil {
+			return err
+		}
+		if err := srv.Start(); err != nil {
+			return err
+		}
+		servers = append(servers, srv)
+		names = append(names, site.Name)
+		fmt.Printf("Serving %s on http://localhost:%d from %s\n", site.Name, site.Port, site.FolderPath())
+		return nil
+	}
+
+	if fs.NArg() > 0 {
+		for _, ref := range fs.Args() {
+			site, err := findSite(cfg, ref)
+			if err == nil {
+				err = start(*site)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "serve: %s: %v\n", ref, err)
+				stopAll(servers)
+				return 1
+			}
+		}
+	} else {
+		summary := launch.Run(cfg, start)
+		for _, f := range summary.Failed {
+			fmt.Fprintf(os.Stderr, "serve: %s: %v\n", f.Site.Name, f.Err)
+		}
+		if len(servers) == 0 {
+			fmt.Fprintln(os.Stderr, "serve: no sites to serve; tick \"Start on Launch\" or name them")
+			return 1
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case sig := <-signals:
+			fmt.Printf("Received %v, stopping\n", sig)
+			stopAll(servers)
+			return 0
+		case <-ticker.C:
+			for i, srv := range servers {
+				if !srv.IsRunning() {
+					fmt.Fprintf(os.Stderr, "serve: %s stopped unexpectedly, see %s\n", names[i], srv.LogsDir)
+					stopAll(servers)
+					return 1
+				}
+			}
+		}
+	}
+}
+
+func stopAll(servers []*server.Server) {
+	for _, srv := range servers {
+		if srv.IsRunning() {
+			srv.Stop()
+		}
+	}
+}
+
+func cmdSystemd(args []string) int {
+	fs := flag.NewFlagSet("systemd", flag.ContinueOnError)
+	out := fs.String("o", ".", "folder to write the unit files to")
+	manager := fs.Bool("manager", false, "one unit serving the sites set to start on launch, instead of one per site")
+	install := fs.Bool("install", false, "write the units to the systemd user folder and reload systemd")
+	enable := fs.Bool("enable", false, "install, then enable and start the units")
+	verify := fs.Bool("verify", false, "only check the given unit files")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *verify {
+		if fs.NArg() == 0 {
+			fmt.Fprintln(os.Stderr, "systemd: expected unit files to verify")
+			return 2
+		}
+		return verifyUnits(fs.Args())
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "systemd: %v\n", err)
+		return 1
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "systemd: %v\n", err)
+		return 1
+	}
+	configPath, err := filepath.Abs(config.Path())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "systemd: %v\n", err)
+		return 1
+	}
+
+	var units []systemd.Unit
+	switch {
+	case *manager:
+		units = append(units, systemd.ForManager(exe, configPath))
+	case fs.NArg() > 0:
+		for _, ref := range fs.Args() {
+			site, err := findSite(cfg, ref)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "systemd: %v\n", err)
+				return 1
+			}
+			units = append(units, systemd.ForSite(*site, exe, configPath))
+		}
+	default:
+		for _, site := range cfg.Sites() {
+			units = append(units, systemd.ForSite(site, exe, configPath))
+		}
+	}
+	if len(units) == 0 {
+		fmt.Fprintln(os.Stderr, "systemd: no sites in this workspace")
+		return 1
+	}
+
+	dir := *out
+	if *install || *enable {
+		if dir, err = systemd.Dir(); err != nil {
+			fmt.Fprintf(os.Stderr, "systemd: %v\n", err)
+			return 1
+		}
+	}
+	paths, err := systemd.Write(units, dir)
+	for _, p := range paths {
+		fmt.Printf("Wrote %s\n", p)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "systemd: %v\n", err)
+		return 1
+	}
+	if code := verifyUnits(paths); code != 0 {
+		return code
+	}
+
+	if *install || *enable {
+		if err := systemd.Systemctl("daemon-reload"); err != nil {
+			fmt.Fprintf(os.Stderr, "systemd: %v\n", err)
+			return 1
+		}
+	}
+	if *enable {
+		for _, u := range units {
+			if err := systemd.Systemctl("enable", "--now", u.Name); err != nil {
+				fmt.Fprintf(os.Stderr, "systemd: %v\n", err)
+				return 1
+			}
+			fmt.Printf("Enabled and started %s\n", u.Name)
+		}
+	}
+	return 0
+}
+
+func verifyUnits(paths []string) int {
+	code := 0
+	for _, p := range paths {
+		problems, err := systemd.Verify(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "systemd: %v\n", err)
+			code = 1
+			continue
+		}
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", p, problem)
+			code = 1
+		}
+	}
+	return code
+}
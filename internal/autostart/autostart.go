@@ -0,0 +1,104 @@
+package autostart
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// FileName matches the desktop file installed by packaging/debian
+const FileName = "shinobi-webserver.desktop"
+
+// BackgroundFlag starts the app hidden in the tray
+const BackgroundFlag = "--background"
+
+// Supported reports whether launching at login can be set up here. Only
+// XDG desktops on Linux are handled.
+func Supported() bool {
+	return runtime.GOOS == "linux"
+}
+
+// Path is the XDG autostart entry, under $XDG_CONFIG_HOME/autostart
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "autostart", FileName), nil
+}
+
+// Enabled reports whether the app is registered to start at login
+func Enabled() bool {
+	p, err := Path()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(p)
+	return err == nil
+}
+
+// Enable registers the running executable to start at login in the
+// background, with args added to its command line
+func Enable(args ...string) error {
+	if !Supported() {
+		return fmt.Errorf("launching at login is not supported on %s", runtime.GOOS)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	p, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, []byte(Entry(exe, append([]string{BackgroundFlag}, args...))), 0644)
+}
+
+// Disable removes the login entry, if there is one
+func Disable() error {
+	p, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Entry returns the contents of the autostart desktop file
+func Entry(exe string, args []string) string {
+	command := []string{quoteExec(exe)}
+	for _, arg := range args {
+		command = append(command, quoteExec(arg))
+	}
+
+	return `[Desktop Entry]
+Name=Shinobi Web Server
+Comment=Manage multiple web servers
+Exec=` + strings.Join(command, " ") + `
+Icon=utilities-terminal
+Terminal=false
+Type=Application
+Categories=Utility;WebDevelopment;
+X-GNOME-Autostart-enabled=true
+`
+}
+
+// quoteExec quotes an Exec argument as the desktop entry spec asks
+func quoteExec(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`=%") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+	return `"` + strings.ReplaceAll(r.Replace(arg), "%", "%%") + `"`
+}
@@ -0,0 +1,121 @@
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"shinobi-webserver/internal/config"
+)
+
+// Build output folders that are named after the project containing them
+var outputDirs = map[string]bool{
+	"dist": true, "public": true, "build": true, "out": true, "www": true, "_site": true,
+}
+
+// Entry files looked for, in order, when adopting a folder
+var entryCandidates = []string{"index.html", "index.htm"}
+
+// ProposeSite suggests a site for an existing folder without touching it:
+// the entry file it contains, a name from the folder (its project's for
+// dist or public), a free port and logs kept under the config directory.
+// The site is not registered.
+func ProposeSite(cfg *config.Config, dir string) (*config.Site, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a folder", dir)
+	}
+
+	port, err := cfg.GetAvailablePort()
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	for _, s := range cfg.Sites() {
+		used[strings.ToLower(s.Name)] = true
+	}
+	name := uniqueName(FolderName(dir), used)
+
+	return &config.Site{
+		Name:       name,
+		Folder:     dir,
+		Port:       port,
+		EntryFile:  DetectEntryFile(dir),
+		LogsFolder: path.Join("logs", Slug(name)),
+	}, nil
+}
+
+// FolderName names a site after dir, or after the project around it when
+// dir is a build output folder
+func FolderName(dir string) string {
+	name := filepath.Base(dir)
+	if outputDirs[strings.ToLower(name)] {
+		if parent := filepath.Base(filepath.Dir(dir)); parent != "." && parent != string(filepath.Separator) {
+			return parent
+		}
+	}
+	return name
+}
+
+// DetectEntryFile returns the index file in dir, or "" if there is none
+func DetectEntryFile(dir string) string {
+	for _, name := range entryCandidates {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return name
+		}
+	}
+	return ""
+}
+
+// PageFiles lists the pages at the top of dir that could be an entry file
+func PageFiles(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var pages []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".html", ".htm", ".md":
+			pages = append(pages, e.Name())
+		}
+	}
+	sort.Strings(pages)
+	return pages
+}
+
+// OpenFolder registers dir from its project file, or as proposed by
+// ProposeSite when it has none
+func OpenFolder(cfg *config.Config, dir string) (Outcome, error) {
+	if HasProject(dir) {
+		return OpenProject(cfg, dir)
+	}
+
+	site, err := ProposeSite(cfg, dir)
+	if err != nil {
+		return Outcome{}, err
+	}
+	if site.EntryFile == "" {
+		return Outcome{}, fmt.Errorf("%s has no index.html or index.htm", site.Folder)
+	}
+	id, err := cfg.RegisterSite(*site)
+	if err != nil {
+		return Outcome{}, err
+	}
+	return Outcome{ID: id, Name: site.Name, Port: site.Port, Folder: site.Folder, Action: "added"}, nil
+}
@@ -0,0 +1,364 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"shinobi-webserver/internal/config"
+)
+
+const (
+	// FormatVersion is the bundle format this build writes
+	FormatVersion = 1
+
+	// Name of the site list inside a .zip bundle
+	ManifestFile = "shinobi-bundle.json"
+)
+
+// Entry is one site definition. Content names the folder inside a .zip
+// bundle that holds the site's files.
+type Entry struct {
+	config.Site
+	Content string `json:"content,omitempty"`
+}
+
+type Bundle struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Sites   []Entry   `json:"sites"`
+
+	// .zip the bundle was read from, for extracting content
+	archive string
+}
+
+// New bundles site definitions without their content
+func New(sites []config.Site) *Bundle {
+	b := &Bundle{
+		Version: FormatVersion,
+		Created: time.Now().UTC().Truncate(time.Second),
+		Sites:   make([]Entry, len(sites)),
+	}
+	for i, site := range sites {
+		site.ID = ""
+		site.Running = false
+		site.DependsOn = nil
+		site.LastStarted = time.Time{}
+		site.LogsFolder = ""
+		b.Sites[i] = Entry{Site: site}
+	}
+	return b
+}
+
+// HasContent reports whether the bundle carries site files
+func (b *Bundle) HasContent() bool {
+	if b.archive == "" {
+		return false
+	}
+	for _, e := range b.Sites {
+		if e.Content != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Write saves the bundle as JSON, YAML (.yaml, .yml) or a .zip. Only a
+// .zip can carry the site folders, which withContent adds.
+func Write(b *Bundle, target string, withContent bool) error {
+	ext := strings.ToLower(filepath.Ext(target))
+	if withContent && ext != ".zip" {
+		return fmt.Errorf("site content can only be bundled in a .zip")
+	}
+
+	switch ext {
+	case ".zip":
+		return writeZip(b, target, withContent)
+	case ".yaml", ".yml":
+		data, err := marshalYAML(b)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	default:
+		data, err := json.MarshalIndent(b, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	}
+}
+
+// Read loads a bundle written by Write
+func Read(source string) (*Bundle, error) {
+	if strings.EqualFold(filepath.Ext(source), ".zip") {
+		return readZip(source)
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data, source)
+}
+
+func parse(data []byte, source string) (*Bundle, error) {
+	// YAML is a superset of JSON, but JSON errors are easier to read
+	if ext := strings.ToLower(filepath.Ext(source)); ext == ".yaml" || ext == ".yml" {
+		var err error
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("%s: %v", source, err)
+		}
+	}
+
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	if b.Version > FormatVersion {
+		return nil, fmt.Errorf("%s: bundle version %d is newer than this build supports (%d)", source, b.Version, FormatVersion)
+	}
+	return &b, nil
+}
+
+// marshalYAML goes through JSON so the field names match the JSON form
+func marshalYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), enc.Close()
+}
+
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func writeZip(b *Bundle, target string, withContent bool) error {
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+
+	err = func() error {
+		out := *b
+		out.Sites = make([]Entry, len(b.Sites))
+		copy(out.Sites, b.Sites)
+
+		if withContent {
+			used := make(map[string]bool)
+			for i := range out.Sites {
+				e := &out.Sites[i]
+				dir := uniqueName("sites/"+Slug(e.Name), used)
+				if err := addFolder(zw, e.FolderPath(), dir, target); err != nil {
+					return fmt.Errorf("site '%s': %v", e.Name, err)
+				}
+				e.Content = dir
+			}
+		}
+
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(ManifestFile)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}()
+
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(target)
+	}
+	return err
+}
+
+// addFolder copies a site folder into the zip under dir, leaving out logs,
+// version control data and the bundle itself
+func addFolder(zw *zip.Writer, folder, dir, target string) error {
+	absTarget, _ := filepath.Abs(target)
+
+	return filepath.WalkDir(folder, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(folder, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel == "logs" || isVCSDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if abs, _ := filepath.Abs(p); abs == absTarget || !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = dir + "/" + rel
+		header.Method = zip.Deflate
+
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(w, src)
+		return err
+	})
+}
+
+func readZip(source string) (*Bundle, error) {
+	zr, err := zip.OpenReader(source)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	f, err := zr.Open(ManifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: not a site bundle (no %s)", source, ManifestFile)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := parse(data, source+"/"+ManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	b.archive = source
+	return b, nil
+}
+
+// extract copies the content of dir in the bundle's archive into dest
+func (b *Bundle) extract(dir, dest string) error {
+	zr, err := zip.OpenReader(b.archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, prefix) || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+
+		// Refuse entries that would land outside dest
+		rel := path.Clean(strings.TrimPrefix(f.Name, prefix))
+		if !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return fmt.Errorf("bundle entry %s points outside the site folder", f.Name)
+		}
+
+		if err := extractFile(f, filepath.Join(dest, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(f *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func isVCSDir(name string) bool {
+	return name == ".git" || name == ".hg" || name == ".svn"
+}
+
+// Slug turns a site name into a folder name
+func Slug(name string) string {
+	s := strings.ToLower(strings.TrimSpace(name))
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '-'
+	}, s)
+	s = strings.Trim(s, "-.")
+	if s == "" {
+		return "site"
+	}
+	return s
+}
+
+// uniqueName appends -2, -3, ... until name is not in used
+func uniqueName(name string, used map[string]bool) string {
+	candidate := name
+	for i := 2; used[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
@@ -0,0 +1,109 @@
+package bundle
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"shinobi-webserver/internal/config"
+)
+
+// Duplicate copies the content of the site with the given ID into folder
+// and registers the copy under name and port with the same options. Logs
+// and version control data are not copied.
+func Duplicate(cfg *config.Config, source, name, folder string, port int) (*config.Site, error) {
+	src := cfg.GetSite(source)
+	if src == nil {
+		return nil, fmt.Errorf("site %s not found", source)
+	}
+	if !cfg.IsPortAvailable(port) {
+		return nil, fmt.Errorf("port %d is already in use", port)
+	}
+
+	from, err := filepath.Abs(src.FolderPath())
+	if err != nil {
+		return nil, err
+	}
+	to, err := filepath.Abs(config.ResolveFolder(folder, cfg.Workspace().Base()))
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(from, to); err == nil && (rel == "." || !strings.HasPrefix(rel, "..")) {
+		return nil, fmt.Errorf("the copy can't go inside %s", from)
+	}
+	if entries, err := os.ReadDir(to); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("%s already exists and is not empty", to)
+	}
+
+	if err := copyContent(from, to); err != nil {
+		os.RemoveAll(to)
+		return nil, err
+	}
+
+	site := *src
+	site.ID = ""
+	site.Name = name
+	site.Folder = to
+	site.Port = port
+	site.LastStarted = time.Time{}
+	site.Running = false
+	if site.LogsFolder != "" {
+		site.LogsFolder = path.Join("logs", Slug(name))
+	}
+	id, err := cfg.RegisterSite(site)
+	if err != nil {
+		os.RemoveAll(to)
+		return nil, err
+	}
+	return cfg.GetSite(id), nil
+}
+
+func copyContent(from, to string) error {
+	if err := os.MkdirAll(to, 0755); err != nil {
+		return err
+	}
+	return filepath.WalkDir(from, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(to, rel)
+
+		if d.IsDir() {
+			if filepath.ToSlash(rel) == "logs" || isVCSDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -0,0 +1,188 @@
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"shinobi-webserver/internal/config"
+)
+
+// Conflict says what to do with a site whose name is already taken
+type Conflict string
+
+const (
+	ConflictRename  Conflict = "rename"
+	ConflictSkip    Conflict = "skip"
+	ConflictReplace Conflict = "replace"
+)
+
+// Conflicts lists the choices in the order the UI offers them
+var Conflicts = []Conflict{ConflictRename, ConflictSkip, ConflictReplace}
+
+type Options struct {
+	OnConflict Conflict
+
+	// Called with the site's ID before it is replaced, e.g. to snapshot it
+	// and stop its server. An error leaves the site as it is.
+	BeforeReplace func(id string) error
+}
+
+// Outcome records what happened to one bundled site
+type Outcome struct {
+	ID           string `json:"id,omitempty"`
+	Name         string `json:"name"`
+	OriginalName string `json:"originalName,omitempty"`
+	Port         int    `json:"port,omitempty"`
+	OriginalPort int    `json:"originalPort,omitempty"`
+	Folder       string `json:"folder,omitempty"`
+	Action       string `json:"action"`
+	Error        string `json:"error,omitempty"`
+}
+
+type Result struct {
+	Sites []Outcome `json:"sites"`
+}
+
+// Failed counts the sites that could not be imported
+func (r *Result) Failed() int {
+	n := 0
+	for _, o := range r.Sites {
+		if o.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Import adds the bundled sites to the current workspace of cfg. Taken
+// ports are replaced with free ones; taken names are handled as
+// opts.OnConflict says. Bundled content is extracted into new folders
+// under the workspace's sites folder.
+func Import(cfg *config.Config, b *Bundle, opts Options) *Result {
+	if opts.OnConflict == "" {
+		opts.OnConflict = ConflictRename
+	}
+
+	result := &Result{Sites: []Outcome{}}
+	for _, entry := range b.Sites {
+		result.Sites = append(result.Sites, importEntry(cfg, b, entry, opts))
+	}
+	return result
+}
+
+func importEntry(cfg *config.Config, b *Bundle, entry Entry, opts Options) Outcome {
+	site := entry.Site
+	site.LastStarted = time.Time{}
+	site.Running = false
+
+	outcome := Outcome{Name: site.Name, Action: "added"}
+	fail := func(err error) Outcome {
+		outcome.Action = "failed"
+		outcome.Error = err.Error()
+		return outcome
+	}
+
+	if strings.TrimSpace(site.Name) == "" {
+		return fail(fmt.Errorf("site has no name"))
+	}
+	if site.EntryFile == "" {
+		site.EntryFile = "index.html"
+	}
+
+	// The site being replaced stays registered until its replacement is
+	// ready, so a failure leaves it as it was
+	var replaced *config.Site
+	if existing := cfg.FindSite(site.Name); existing != nil {
+		switch opts.OnConflict {
+		case ConflictSkip:
+			outcome.Action = "skipped"
+			return outcome
+		case ConflictReplace:
+			if opts.BeforeReplace != nil {
+				if err := opts.BeforeReplace(existing.ID); err != nil {
+					outcome.Action = "skipped"
+					outcome.Error = fmt.Sprintf("snapshot failed: %v", err)
+					return outcome
+				}
+			}
+			// The replacement takes over the old site's identity
+			site.ID = existing.ID
+			replaced = existing
+			outcome.Action = "replaced"
+		default:
+			used := make(map[string]bool)
+			for _, s := range cfg.Sites() {
+				used[strings.ToLower(s.Name)] = true
+			}
+			site.Name = uniqueName(site.Name, used)
+			outcome.OriginalName = outcome.Name
+			outcome.Name = site.Name
+			if site.LogsFolder != "" {
+				site.LogsFolder = path.Join("logs", Slug(site.Name))
+			}
+		}
+	}
+
+	keepPort := replaced != nil && site.Port == replaced.Port
+	if site.Port < 1 || site.Port > 65535 || !keepPort && !cfg.IsPortAvailable(site.Port) {
+		port, err := cfg.GetAvailablePort()
+		if err != nil {
+			return fail(err)
+		}
+		if site.Port != 0 {
+			outcome.OriginalPort = site.Port
+		}
+		site.Port = port
+	}
+	outcome.Port = site.Port
+
+	extracted := ""
+	if entry.Content != "" && b.archive != "" {
+		dest := FreeFolder(filepath.Join(cfg.Workspace().SitesDir(), Slug(site.Name)))
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			return fail(err)
+		}
+		if err := b.extract(entry.Content, dest); err != nil {
+			os.RemoveAll(dest)
+			return fail(err)
+		}
+		site.Folder = dest
+		extracted = dest
+	} else if strings.TrimSpace(site.Folder) == "" {
+		site.Folder = filepath.Join(cfg.Workspace().SitesDir(), Slug(site.Name))
+	}
+
+	id := site.ID
+	var err error
+	if replaced != nil {
+		err = cfg.UpdateSite(replaced.ID, site)
+	} else {
+		id, err = cfg.RegisterSite(site)
+	}
+	if err != nil {
+		if extracted != "" {
+			os.RemoveAll(extracted)
+		}
+		return fail(err)
+	}
+	outcome.ID = id
+	if registered := cfg.GetSite(id); registered != nil {
+		outcome.Folder = registered.FolderPath()
+	}
+	return outcome
+}
+
+// FreeFolder returns dir, or dir-2, dir-3, ... if it already exists
+func FreeFolder(dir string) string {
+	candidate := dir
+	for i := 2; ; i++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", dir, i)
+	}
+}
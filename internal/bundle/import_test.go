@@ -0,0 +1,125 @@
+package bundle
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shinobi-webserver/internal/config"
+)
+
+// setup points the config at a temporary folder and registers one site
+func setup(t *testing.T) (*config.Config, config.Site) {
+	t.Helper()
+	dir := t.TempDir()
+	config.SetPath(filepath.Join(dir, "config.json"))
+	t.Cleanup(func() { config.SetPath("") })
+
+	cfg := config.NewDefault()
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	folder := filepath.Join(dir, "sites", "docs")
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	id, err := cfg.RegisterSite(config.Site{Name: "Docs", Port: 8123, Folder: folder, EntryFile: "index.html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg, *cfg.GetSite(id)
+}
+
+// writeArchive writes a .zip holding the given files and returns a bundle
+// whose only site has its content in dir
+func writeArchive(t *testing.T, dir string, files ...string) *Bundle {
+	t.Helper()
+	archive := filepath.Join(t.TempDir(), "bundle.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(name))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	return &Bundle{
+		Version: FormatVersion,
+		Sites:   []Entry{{Site: config.Site{Name: "Docs", Port: 8124, EntryFile: "index.html"}, Content: dir}},
+		archive: archive,
+	}
+}
+
+func TestImportReplace(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		hookErr error
+		failed  bool
+	}{
+		{"content extracted", []string{"sites/docs/index.html"}, nil, false},
+		{"content outside the folder", []string{"sites/docs/index.html", "sites/docs/../../evil.html"}, nil, true},
+		{"snapshot failed", []string{"sites/docs/index.html"}, errors.New("disk full"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, original := setup(t)
+			b := writeArchive(t, "sites/docs", tt.files...)
+
+			var stopped string
+			result := Import(cfg, b, Options{
+				OnConflict: ConflictReplace,
+				BeforeReplace: func(id string) error {
+					stopped = id
+					return tt.hookErr
+				},
+			})
+			if len(result.Sites) != 1 {
+				t.Fatalf("got %d outcomes, want 1", len(result.Sites))
+			}
+			outcome := result.Sites[0]
+			if stopped != original.ID {
+				t.Errorf("BeforeReplace got %q, want %q", stopped, original.ID)
+			}
+
+			site := cfg.GetSite(original.ID)
+			if site == nil {
+				t.Fatal("the replaced site is gone")
+			}
+			if len(cfg.Sites()) != 1 {
+				t.Errorf("got %d sites, want 1", len(cfg.Sites()))
+			}
+
+			if tt.failed {
+				if outcome.Error == "" {
+					t.Fatalf("outcome = %+v, want an error", outcome)
+				}
+				if site.Port != original.Port || site.FolderPath() != original.FolderPath() {
+					t.Errorf("site = %+v, want it unchanged", site)
+				}
+				return
+			}
+			if outcome.Error != "" || outcome.Action != "replaced" {
+				t.Fatalf("outcome = %+v, want replaced", outcome)
+			}
+			if site.Port != 8124 || site.FolderPath() == original.FolderPath() {
+				t.Errorf("site = %+v, want the bundled one", site)
+			}
+			if _, err := os.Stat(filepath.Join(site.FolderPath(), "index.html")); err != nil {
+				t.Errorf("content not extracted: %v", err)
+			}
+		})
+	}
+}
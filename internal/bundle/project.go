@@ -0,0 +1,143 @@
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"shinobi-webserver/internal/config"
+	"shinobi-webserver/internal/siteconfig"
+)
+
+// ProjectFile describes a site from inside its own folder, so it can be
+// committed alongside the content and opened on another machine. A
+// shinobi.yaml (see siteconfig.FileNames) is read the same way.
+const ProjectFile = "shinobi.json"
+
+// HasProject reports whether dir contains a project file
+func HasProject(dir string) bool {
+	return siteconfig.Find(dir) != ""
+}
+
+// LoadProject reads the project file in dir. The site folder is dir, or
+// the project's folder relative to dir (e.g. "dist"); the name defaults
+// to the folder name.
+func LoadProject(dir string) (*config.Site, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	p := siteconfig.Find(dir)
+	if p == "" {
+		return nil, fmt.Errorf("%s has no %s", dir, ProjectFile)
+	}
+	data, err := readProject(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var site config.Site
+	if err := json.Unmarshal(data, &site); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+
+	site.Folder = config.ResolveFolder(site.Folder, dir)
+	if site.Folder == "" {
+		site.Folder = dir
+	}
+	if site.Name == "" {
+		site.Name = filepath.Base(dir)
+	}
+	if site.EntryFile == "" {
+		site.EntryFile = "index.html"
+	}
+	site.ID = ""
+	site.Running = false
+	site.DependsOn = nil
+	site.LastStarted = time.Time{}
+	site.LogsFolder = ""
+	return &site, nil
+}
+
+// WriteProject saves the site definition into the project file in its
+// folder, creating shinobi.json if there is none. Settings already in the
+// file, such as headers or redirects, are kept; YAML comments are not.
+func WriteProject(site config.Site) (string, error) {
+	folder := site.FolderPath()
+	p := siteconfig.Find(folder)
+	if p == "" {
+		p = filepath.Join(folder, ProjectFile)
+	}
+
+	doc := map[string]interface{}{}
+	if data, err := readProject(p); err == nil {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return "", fmt.Errorf("%s: %v", p, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	site.ID = ""
+	site.Running = false
+	site.DependsOn = nil
+	site.Folder = "."
+	site.LastStarted = time.Time{}
+	site.LogsFolder = ""
+	data, err := json.Marshal(site)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", err
+	}
+
+	if ext := strings.ToLower(filepath.Ext(p)); ext == ".yaml" || ext == ".yml" {
+		data, err = marshalYAML(doc)
+	} else {
+		data, err = json.MarshalIndent(doc, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return "", err
+	}
+	return p, os.WriteFile(p, data, 0644)
+}
+
+// readProject returns a project file as JSON
+func readProject(p string) ([]byte, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	if ext := strings.ToLower(filepath.Ext(p)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("%s: %v", p, err)
+		}
+	}
+	return data, nil
+}
+
+// OpenProject registers the site described by the project file in dir,
+// renaming it or picking a new port if those are taken. Its logs are kept
+// out of the folder.
+func OpenProject(cfg *config.Config, dir string) (Outcome, error) {
+	site, err := LoadProject(dir)
+	if err != nil {
+		return Outcome{}, err
+	}
+	site.LogsFolder = path.Join("logs", Slug(site.Name))
+
+	b := &Bundle{Version: FormatVersion, Sites: []Entry{{Site: *site}}}
+	outcome := Import(cfg, b, Options{OnConflict: ConflictRename}).Sites[0]
+	if outcome.Error != "" {
+		return outcome, errors.New(outcome.Error)
+	}
+	return outcome, nil
+}
@@ -0,0 +1,351 @@
+package checker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"shinobi-webserver/internal/links"
+	"shinobi-webserver/internal/siteconfig"
+)
+
+const DefaultMaxAssetSize = 1 << 20
+
+type Kind string
+
+const (
+	KindBroken       Kind = "broken"
+	KindRedirect     Kind = "redirect"
+	KindOversized    Kind = "oversized"
+	KindMixedContent Kind = "mixed-content"
+	KindOrphan       Kind = "orphan"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+type Issue struct {
+	Kind     Kind     `json:"kind"`
+	Severity Severity `json:"severity"`
+	URL      string   `json:"url"`
+	Source   string   `json:"source,omitempty"`
+	Status   int      `json:"status,omitempty"`
+	Size     int64    `json:"size,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+type Report struct {
+	BaseURL  string        `json:"baseUrl"`
+	Checked  int           `json:"checked"`
+	External int           `json:"external"`
+	Duration time.Duration `json:"duration"`
+	Issues   []Issue       `json:"issues"`
+}
+
+// Failed reports whether any issue is an error, for gating releases
+func (r *Report) Failed() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) Count(kind Kind) int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+type Options struct {
+	// Assets larger than this many bytes are reported; 0 uses DefaultMaxAssetSize
+	MaxAssetSize int64
+	Timeout      time.Duration
+}
+
+// Check crawls the site served at baseURL starting from entryFile and
+// compares what it reached with the files in folder
+func Check(baseURL, folder, entryFile string, opts Options) (*Report, error) {
+	if opts.MaxAssetSize <= 0 {
+		opts.MaxAssetSize = DefaultMaxAssetSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	c := &checker{
+		base: strings.TrimSuffix(baseURL, "/"),
+		opts: opts,
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			// Redirects are reported, then followed through the queue
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		seen:    make(map[string]bool),
+		visited: make(map[string]bool),
+		report:  &Report{BaseURL: baseURL, Issues: []Issue{}},
+	}
+
+	start := time.Now()
+	entry := "/"
+	if entryFile != "" {
+		entry = path.Join("/", filepath.ToSlash(entryFile))
+	}
+	c.enqueue(entry, "")
+	if err := c.run(); err != nil {
+		return nil, err
+	}
+
+	if err := c.findOrphans(folder); err != nil {
+		return nil, err
+	}
+
+	c.report.Duration = time.Since(start)
+	return c.report, nil
+}
+
+type queued struct {
+	path     string
+	source   string
+	resource bool
+}
+
+type checker struct {
+	base    string
+	opts    Options
+	client  *http.Client
+	queue   []queued
+	seen    map[string]bool
+	visited map[string]bool
+	report  *Report
+}
+
+func (c *checker) enqueue(p, source string) {
+	if c.seen[p] {
+		return
+	}
+	c.seen[p] = true
+	c.queue = append(c.queue, queued{path: p, source: source})
+}
+
+func (c *checker) add(issue Issue) {
+	c.report.Issues = append(c.report.Issues, issue)
+}
+
+func (c *checker) run() error {
+	for len(c.queue) > 0 {
+		q := c.queue[0]
+		c.queue = c.queue[1:]
+		c.report.Checked++
+
+		// Paths are kept decoded for the report; names with %, # or ?
+		// need escaping to be fetched
+		resp, err := c.client.Get(c.base + (&url.URL{Path: q.path}).EscapedPath())
+		if err != nil {
+			// The site itself being unreachable is fatal, single URLs are not
+			if q.source == "" {
+				return fmt.Errorf("site is not reachable: %v", err)
+			}
+			c.add(Issue{Kind: KindBroken, Severity: SeverityError, URL: q.path, Source: q.source, Detail: err.Error()})
+			continue
+		}
+
+		body, size, err := readBody(resp)
+		resp.Body.Close()
+		if err != nil {
+			c.add(Issue{Kind: KindBroken, Severity: SeverityError, URL: q.path, Source: q.source, Detail: err.Error()})
+			continue
+		}
+
+		switch {
+		case resp.StatusCode >= 300 && resp.StatusCode < 400:
+			c.redirect(q, resp)
+
+		case resp.StatusCode >= 400:
+			c.add(Issue{Kind: KindBroken, Severity: SeverityError, URL: q.path, Source: q.source, Status: resp.StatusCode})
+
+		default:
+			c.visited[q.path] = true
+			contentType := resp.Header.Get("Content-Type")
+
+			if size > c.opts.MaxAssetSize && !strings.HasPrefix(contentType, "text/html") {
+				c.add(Issue{
+					Kind:     KindOversized,
+					Severity: SeverityWarning,
+					URL:      q.path,
+					Source:   q.source,
+					Size:     size,
+					Detail:   fmt.Sprintf("%s exceeds the %s limit", formatSize(size), formatSize(c.opts.MaxAssetSize)),
+				})
+			}
+
+			switch {
+			case strings.HasPrefix(contentType, "text/html"):
+				c.scan(q.path, links.FromHTML(bytes.NewReader(body)))
+			case strings.HasPrefix(contentType, "text/css"):
+				c.scan(q.path, links.FromCSS(string(body)))
+			}
+		}
+	}
+	return nil
+}
+
+func (c *checker) redirect(q queued, resp *http.Response) {
+	location := resp.Header.Get("Location")
+	target, internal := links.Internal(q.path, location)
+
+	// Adding a directory slash or dropping index.html is routine, not worth reporting
+	if !internal || (target != q.path+"/" && target+"index.html" != q.path) {
+		c.add(Issue{
+			Kind:     KindRedirect,
+			Severity: SeverityWarning,
+			URL:      q.path,
+			Source:   q.source,
+			Status:   resp.StatusCode,
+			Detail:   "redirects to " + location,
+		})
+	}
+	if internal {
+		c.enqueue(target, q.source)
+	}
+}
+
+func (c *checker) scan(page string, refs []links.Ref) {
+	for _, ref := range refs {
+		if strings.HasPrefix(strings.ToLower(ref.URL), "http://") {
+			if !ref.IsPageLink() {
+				c.add(Issue{
+					Kind:     KindMixedContent,
+					Severity: SeverityError,
+					URL:      ref.URL,
+					Source:   page,
+					Detail:   fmt.Sprintf("insecure <%s %s> breaks when served over HTTPS", ref.Tag, ref.Attr),
+				})
+			}
+			c.report.External++
+			continue
+		}
+
+		target, ok := links.Internal(page, ref.URL)
+		if !ok {
+			if strings.Contains(ref.URL, "://") || strings.HasPrefix(ref.URL, "//") {
+				c.report.External++
+			}
+			continue
+		}
+		c.enqueue(target, page)
+	}
+}
+
+// findOrphans reports files in folder that no crawled URL reached
+func (c *checker) findOrphans(folder string) error {
+	var orphans []string
+
+	err := filepath.WalkDir(folder, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == folder {
+			return nil
+		}
+
+		rel, err := filepath.Rel(folder, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		name := d.Name()
+
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || siteconfig.IsFileName(name) || (d.IsDir() && rel == "logs") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || c.reached(rel) {
+			return nil
+		}
+
+		orphans = append(orphans, "/"+rel)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(orphans)
+	for _, o := range orphans {
+		c.add(Issue{Kind: KindOrphan, Severity: SeverityWarning, URL: o, Detail: "not linked from any page"})
+	}
+	return nil
+}
+
+// reached reports whether the file was served under any of its URLs
+func (c *checker) reached(rel string) bool {
+	candidates := []string{"/" + rel}
+
+	base := path.Base(rel)
+	if base == "index.html" || base == "index.htm" {
+		dir := path.Dir(rel)
+		if dir == "." {
+			candidates = append(candidates, "/")
+		} else {
+			candidates = append(candidates, "/"+dir+"/", "/"+dir)
+		}
+	}
+	if ext := path.Ext(rel); strings.EqualFold(ext, ".md") {
+		stem := "/" + strings.TrimSuffix(rel, ext)
+		candidates = append(candidates, stem+".html", stem)
+		if strings.EqualFold(path.Base(stem), "readme") || strings.EqualFold(path.Base(stem), "index") {
+			candidates = append(candidates, strings.TrimSuffix(stem, path.Base(stem)))
+		}
+	}
+
+	for _, u := range candidates {
+		if c.visited[u] {
+			return true
+		}
+	}
+	return false
+}
+
+func readBody(resp *http.Response) ([]byte, int64, error) {
+	contentType := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "text/html") || strings.HasPrefix(contentType, "text/css") {
+		body, err := io.ReadAll(resp.Body)
+		return body, int64(len(body)), err
+	}
+
+	// Other assets only need measuring
+	n, err := io.Copy(io.Discard, resp.Body)
+	return nil, n, err
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
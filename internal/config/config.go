@@ -1,189 +1,574 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
+
+	"shinobi-webserver/internal/filelock"
+	"shinobi-webserver/internal/netsim"
 )
 
 type Site struct {
+	// Generated when the site is added and never changed; Name is only a
+	// label
+	ID          string    `json:"id,omitempty"`
 	Name        string    `json:"name"`
 	Folder      string    `json:"folder"`
 	Port        int       `json:"port"`
 	EntryFile   string    `json:"entryFile"`
-	LastStarted time.Time `json:"lastStarted"`
-	Running     bool      `json:"-"`
+	LastStarted time.Time `json:"lastStarted,omitzero"`
+
+	// Running when the app last exited; such sites are started on launch
+	Running bool `json:"running,omitempty"`
+
+	// Start on every launch. Lower StartOrder goes first, and sites in
+	// DependsOn (by ID) are started before this one.
+	AutoStart  bool     `json:"autoStart,omitempty"`
+	StartOrder int      `json:"startOrder,omitempty"`
+	DependsOn  []string `json:"dependsOn,omitempty"`
+
+	// HAR file whose recorded responses are served as a mock
+	HARMock      string `json:"harMock,omitempty"`
+	HARMatchBody bool   `json:"harMatchBody,omitempty"`
+
+	// Name of the network simulation profile, empty for full speed
+	NetworkProfile string `json:"networkProfile,omitempty"`
+
+	// Render .md files as HTML, optionally with a custom layout template
+	Markdown       bool   `json:"markdown,omitempty"`
+	MarkdownLayout string `json:"markdownLayout,omitempty"`
+
+	// Process .html files with html/template, using _includes and _data
+	Templates bool `json:"templates,omitempty"`
+
+	// Where logs are written; empty keeps them in a logs folder inside the
+	// site folder. Relative paths are under the config directory.
+	LogsFolder string `json:"logsFolder,omitempty"`
+
+	// Base of the owning workspace that a relative Folder is resolved against
+	base string
 }
 
 type AppSettings struct {
-	AutoPortMin int `json:"autoPortMin"`
-	AutoPortMax int `json:"autoPortMax"`
+	// User-defined network simulation profiles, in addition to the built-in ones
+	NetworkProfiles []netsim.Profile `json:"networkProfiles,omitempty"`
+
+	// Days deleted sites stay in the trash; 0 uses DefaultTrashDays
+	TrashDays int `json:"trashDays,omitempty"`
+
+	// Snapshot sites before an import replaces them or a template is applied
+	AutoSnapshot bool `json:"autoSnapshot,omitempty"`
+
+	// Start a server again when it stops on its own
+	RestartCrashed bool `json:"restartCrashed,omitempty"`
+
+	Notifications Notifications `json:"notifications"`
+}
+
+const DefaultTrashDays = 30
+
+// TrashRetention is how long deleted sites can be restored
+func (s AppSettings) TrashRetention() time.Duration {
+	days := s.TrashDays
+	if days <= 0 {
+		days = DefaultTrashDays
+	}
+	return time.Duration(days) * 24 * time.Hour
 }
 
 type Config struct {
-	Sites       []Site      `json:"sites"`
-	AppSettings AppSettings `json:"appSettings"`
+	Version         int         `json:"version"`
+	ActiveWorkspace string      `json:"activeWorkspace"`
+	Workspaces      []Workspace `json:"workspaces"`
+	AppSettings     AppSettings `json:"appSettings"`
+
+	// Guards everything; a reload from Watch replaces the workspaces and
+	// settings, so other packages read them through the accessors, which
+	// return copies
+	mu sync.RWMutex
+	// File contents as last loaded or saved, to tell our own writes from
+	// external edits
+	saved []byte
+	// Workspace selected in this process, which may differ from the saved one
+	workspace string
 }
 
 const configFile = "config.json"
 
 func NewDefault() *Config {
 	return &Config{
-		Sites: []Site{},
-		AppSettings: AppSettings{
-			AutoPortMin: 8000,
-			AutoPortMax: 9000,
-		},
+		Version:         CurrentVersion,
+		ActiveWorkspace: DefaultWorkspace,
+		Workspaces:      []Workspace{newWorkspace(DefaultWorkspace)},
 	}
 }
 
 func Load() (*Config, error) {
-	data, err := os.ReadFile(configFile)
+	path := Path()
+
+	lock, err := filelock.Acquire(lockPath())
 	if err != nil {
 		return nil, err
 	}
+	defer lock.Release()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && configPath == "" {
+		// Older builds kept config.json in the working directory
+		data, err = importLegacy(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	version, upgraded, err := migrate(data, filepath.Dir(path))
+	if err != nil {
+		return nil, newSyntaxError(path, data, err)
+	}
+	if upgraded != nil {
+		if _, err := backup(path, data, version); err != nil {
+			return nil, fmt.Errorf("failed to back up config before migrating: %v", err)
+		}
+		if err := writeFileAtomic(path, upgraded, 0644); err != nil {
+			return nil, err
+		}
+		data = upgraded
+	}
 
 	var cfg Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, newSyntaxError(path, data, err)
+	}
+	cfg.saved = data
+	cfg.bind()
+
+	// A file with problems is left as the user wrote it; sites get IDs in
+	// memory only
+	if problems := cfg.Validate(data); len(problems) > 0 {
+		return &cfg, &ValidationError{Problems: problems}
+	}
+
+	// Sites added by hand get their IDs saved right away
+	patched, err := ensureRawIDs(data)
+	if err != nil {
+		return nil, newSyntaxError(path, data, err)
+	}
+	if patched == nil {
+		return &cfg, nil
+	}
+	if err := writeFileAtomic(path, patched, 0644); err != nil {
+		return nil, err
+	}
+	var withIDs Config
+	if err := json.Unmarshal(patched, &withIDs); err != nil {
 		return nil, err
 	}
+	withIDs.saved = patched
+	withIDs.bind()
+	return &withIDs, nil
+}
 
-	return &cfg, nil
+// MoveAside renames an unusable config file out of the way so a fresh one
+// can be saved, and returns where it was moved to
+func MoveAside() (string, error) {
+	lock, err := filelock.Acquire(lockPath())
+	if err != nil {
+		return "", err
+	}
+	defer lock.Release()
+
+	aside := fmt.Sprintf("%s.broken-%s", Path(), time.Now().Format("2006-01-02_15-04-05"))
+	return aside, os.Rename(Path(), aside)
 }
 
 func (c *Config) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lock, err := filelock.Acquire(lockPath())
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	// Never replace a file we failed to load; it may only need a fix
+	if c.saved == nil {
+		if _, err := os.Stat(Path()); err == nil {
+			return fmt.Errorf("%s was not loaded and won't be overwritten", Path())
+		}
+	}
+
+	return c.write()
+}
+
+// update runs a read-modify-write cycle under the config file lock, so
+// changes another instance saved since we loaded aren't clobbered
+func (c *Config) update(modify func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lock, err := filelock.Acquire(lockPath())
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if err := c.reload(); err != nil {
+		return err
+	}
+	if err := modify(); err != nil {
+		return err
+	}
+	return c.write()
+}
+
+// reload picks up the file's contents if they changed since we last saw
+// them. Callers hold c.mu and the file lock.
+func (c *Config) reload() error {
+	data, err := os.ReadFile(Path())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, c.saved) {
+		return nil
+	}
+
+	if _, upgraded, err := migrate(data, Dir()); err != nil {
+		return err
+	} else if upgraded != nil {
+		data = upgraded
+	}
+
+	var latest Config
+	if err := json.Unmarshal(data, &latest); err != nil {
+		return fmt.Errorf("%s: %v", Path(), err)
+	}
+
+	c.Version = latest.Version
+	c.ActiveWorkspace = latest.ActiveWorkspace
+	c.Workspaces = latest.Workspaces
+	c.AppSettings = latest.AppSettings
+	c.saved = data
+	c.bind()
+	return nil
+}
+
+func (c *Config) write() error {
+	c.Version = CurrentVersion
+
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(configFile, data, 0644)
+	if err := writeFileAtomic(Path(), data, 0644); err != nil {
+		return err
+	}
+	c.saved = data
+	return nil
+}
+
+func lockPath() string {
+	return Path() + ".lock"
 }
 
-func (c *Config) AddSite(site Site) error {
+// AddSite creates the site's folder and registers it, returning its ID
+func (c *Config) AddSite(site Site) (string, error) {
 	// Validate port
 	if !c.IsPortAvailable(site.Port) {
-		return fmt.Errorf("port %d is already in use", site.Port)
+		return "", fmt.Errorf("port %d is already in use", site.Port)
 	}
 
+	ws := c.Workspace()
+	site.Folder = NormalizeFolder(site.Folder, ws.Base())
+	site.base = ws.Base()
+	folder := site.FolderPath()
+
 	// Create site folder
-	if err := os.MkdirAll(site.Folder, 0755); err != nil {
-		return err
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return "", err
 	}
 
 	// Create logs folder
-	logsDir := filepath.Join(site.Folder, "logs")
+	logsDir := filepath.Join(folder, "logs")
 	if err := os.MkdirAll(logsDir, 0755); err != nil {
-		return err
+		return "", err
 	}
 
-	// Create default index file
-	indexPath := filepath.Join(site.Folder, site.EntryFile)
-	defaultContent := `<!DOCTYPE html>
-<html>
-<head>
-    <title>` + site.Name + `</title>
-    <style>
-        body {
-            font-family: Arial, sans-serif;
-            margin: 40px;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            color: white;
-            min-height: 100vh;
-            display: flex;
-            flex-direction: column;
-            align-items: center;
-            justify-content: center;
-            text-align: center;
-        }
-        .container {
-            background: rgba(255, 255, 255, 0.1);
-            padding: 40px;
-            border-radius: 15px;
-            backdrop-filter: blur(10px);
-            box-shadow: 0 8px 32px rgba(0, 0, 0, 0.2);
-        }
-        h1 {
-            font-size: 2.5em;
-            margin-bottom: 20px;
-        }
-        p {
-            font-size: 1.2em;
-            margin-bottom: 10px;
-        }
-        .status {
-            background: rgba(0, 255, 0, 0.2);
-            padding: 10px 20px;
-            border-radius: 20px;
-            margin-top: 20px;
-            display: inline-block;
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <h1>🚀 ` + site.Name + `</h1>
-        <p>Your site is running successfully!</p>
-        <p>Server Port: <strong>` + fmt.Sprintf("%d", site.Port) + `</strong></p>
-        <p>Local URL: <strong>http://localhost:` + fmt.Sprintf("%d", site.Port) + `</strong></p>
-        <div class="status">
-            ✅ Site is online and ready
-        </div>
-    </div>
-</body>
-</html>`
-
-	if err := os.WriteFile(indexPath, []byte(defaultContent), 0644); err != nil {
-		return err
+	return c.RegisterSite(site)
+}
+
+// RegisterSite adds a site to the current workspace without touching its
+// folder, returning its ID
+func (c *Config) RegisterSite(site Site) (string, error) {
+	return c.RegisterSiteIn(c.Workspace().Name, site)
+}
+
+// RegisterSiteIn adds a site to the named workspace without touching its
+// folder, returning its ID
+func (c *Config) RegisterSiteIn(workspace string, site Site) (string, error) {
+	site.Name = strings.TrimSpace(site.Name)
+	if site.Name == "" {
+		return "", fmt.Errorf("site name must not be empty")
+	}
+
+	err := c.update(func() error {
+		ws := c.findWorkspace(workspace)
+		if ws == nil {
+			return fmt.Errorf("workspace '%s' not found", workspace)
+		}
+		site.Folder = NormalizeFolder(site.Folder, ws.Base())
+		site.base = ws.Base()
+
+		// Keep an imported or restored site's ID unless it is taken
+		if site.ID == "" || c.getSite(site.ID) != nil {
+			site.ID = c.newSiteID()
+		}
+
+		// Another instance may have taken the port in the meantime
+		for _, ws := range c.Workspaces {
+			for _, s := range ws.Sites {
+				if s.Port == site.Port {
+					return fmt.Errorf("port %d is already used by site '%s' in workspace '%s'", site.Port, s.Name, ws.Name)
+				}
+			}
+		}
+		for _, s := range ws.Sites {
+			if strings.EqualFold(s.Name, site.Name) {
+				return fmt.Errorf("a site named '%s' already exists", s.Name)
+			}
+		}
+		ws.Sites = append(ws.Sites, site)
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
+	return site.ID, nil
+}
 
-	c.Sites = append(c.Sites, site)
-	return c.Save()
+func (c *Config) RemoveSite(id string) error {
+	return c.update(func() error {
+		ws, i := c.locateSite(id)
+		if ws == nil {
+			return fmt.Errorf("site %s not found", id)
+		}
+		ws.Sites = append(ws.Sites[:i], ws.Sites[i+1:]...)
+		return nil
+	})
 }
 
-func (c *Config) RemoveSite(name string) error {
-	for i, site := range c.Sites {
-		if site.Name == name {
-			c.Sites = append(c.Sites[:i], c.Sites[i+1:]...)
-			return c.Save()
+// RemoveBrokenSites removes the sites that have error-level problems in
+// the config as it is on disk now, and returns their names
+func (c *Config) RemoveBrokenSites() ([]string, error) {
+	var names []string
+	err := c.update(func() error {
+		invalid := &ValidationError{Problems: c.Validate(nil)}
+		refs := invalid.SitesWithErrors()
+		// Remove back to front so the indexes stay valid
+		for i := len(refs) - 1; i >= 0; i-- {
+			ref := refs[i]
+			if ref.Workspace >= len(c.Workspaces) {
+				continue
+			}
+			ws := &c.Workspaces[ref.Workspace]
+			if ref.Site < len(ws.Sites) {
+				names = append([]string{ws.Sites[ref.Site].Name}, names...)
+				ws.Sites = append(ws.Sites[:ref.Site], ws.Sites[ref.Site+1:]...)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return names, nil
 }
 
-func (c *Config) UpdateSite(name string, updated Site) error {
-	for i, site := range c.Sites {
-		if site.Name == name {
-			c.Sites[i] = updated
-			return c.Save()
+// UpdateSite replaces the site with the given ID, which may rename it or
+// move it to another port. The ID itself never changes.
+func (c *Config) UpdateSite(id string, updated Site) error {
+	updated.ID = id
+	updated.Name = strings.TrimSpace(updated.Name)
+	if updated.Name == "" {
+		return fmt.Errorf("site name must not be empty")
+	}
+	if updated.Port < 1 || updated.Port > 65535 {
+		return fmt.Errorf("port %d is out of range", updated.Port)
+	}
+
+	return c.update(func() error {
+		ws, index := c.locateSite(id)
+		if ws == nil {
+			return fmt.Errorf("site %s not found", id)
+		}
+		updated.Folder = NormalizeFolder(updated.Folder, ws.Base())
+		updated.base = ws.Base()
+
+		for _, site := range ws.Sites {
+			if site.ID != id && strings.EqualFold(site.Name, updated.Name) {
+				return fmt.Errorf("a site named '%s' already exists", site.Name)
+			}
+		}
+		for _, other := range c.Workspaces {
+			for _, site := range other.Sites {
+				if site.Port == updated.Port && site.ID != id {
+					return fmt.Errorf("port %d is already used by site '%s' in workspace '%s'", updated.Port, site.Name, other.Name)
+				}
+			}
 		}
+
+		ws.Sites[index] = updated
+		return nil
+	})
+}
+
+// GetSite returns a copy of the site with the given ID from any workspace;
+// change it with UpdateSite
+func (c *Config) GetSite(id string) *Site {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if site := c.getSite(id); site != nil {
+		copied := *site
+		return &copied
 	}
 	return nil
 }
 
-func (c *Config) GetSite(name string) *Site {
-	for i, site := range c.Sites {
-		if site.Name == name {
-			return &c.Sites[i]
+// getSite is GetSite for callers holding c.mu
+func (c *Config) getSite(id string) *Site {
+	ws, i := c.locateSite(id)
+	if ws == nil {
+		return nil
+	}
+	return &ws.Sites[i]
+}
+
+// FindSite returns a copy of the site of the current workspace with the
+// given name, ignoring case
+func (c *Config) FindSite(name string) *Site {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, site := range c.current().Sites {
+		if strings.EqualFold(site.Name, name) {
+			return &site
 		}
 	}
 	return nil
 }
 
+// WorkspaceOf returns the name of the workspace holding the site
+func (c *Config) WorkspaceOf(id string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if ws, _ := c.locateSite(id); ws != nil {
+		return ws.Name
+	}
+	return ""
+}
+
+func (c *Config) locateSite(id string) (*Workspace, int) {
+	if id == "" {
+		return nil, -1
+	}
+	for w := range c.Workspaces {
+		ws := &c.Workspaces[w]
+		for i := range ws.Sites {
+			if ws.Sites[i].ID == id {
+				return ws, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+// NetworkProfiles lists the built-in profiles followed by the user-defined ones
+func (c *Config) NetworkProfiles() []netsim.Profile {
+	return append(netsim.Builtin(), c.Settings().NetworkProfiles...)
+}
+
+// Settings returns a copy of the app-wide settings; change them with
+// UpdateSettings
+func (c *Config) Settings() AppSettings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	settings := c.AppSettings
+	settings.NetworkProfiles = append([]netsim.Profile(nil), settings.NetworkProfiles...)
+	settings.Notifications.Muted = append([]string(nil), settings.Notifications.Muted...)
+	return settings
+}
+
+// UpdateSettings changes the app-wide settings and saves them
+func (c *Config) UpdateSettings(modify func(*AppSettings)) error {
+	return c.update(func() error {
+		modify(&c.AppSettings)
+		return nil
+	})
+}
+
+// SetRunning records whether a site is running, and when it last started
+// unless lastStarted is zero. Only those fields are written, so edits to
+// the site picked up by the reload are kept.
+func (c *Config) SetRunning(id string, running bool, lastStarted time.Time) error {
+	return c.update(func() error {
+		site := c.getSite(id)
+		if site == nil {
+			return fmt.Errorf("site %s not found", id)
+		}
+		site.Running = running
+		if !lastStarted.IsZero() {
+			site.LastStarted = lastStarted
+		}
+		return nil
+	})
+}
+
+// SaveRunning records which sites are running, so the next launch can
+// start them again
+func (c *Config) SaveRunning(running map[string]bool) error {
+	return c.update(func() error {
+		for w := range c.Workspaces {
+			for i := range c.Workspaces[w].Sites {
+				site := &c.Workspaces[w].Sites[i]
+				site.Running = running[site.ID]
+			}
+		}
+		return nil
+	})
+}
+
 func (c *Config) IsPortAvailable(port int) bool {
-	// Check if port is already used by other sites
-	for _, site := range c.Sites {
-		if site.Port == port {
-			return false
+	// Check if port is already used by other sites, in any workspace, then
+	// if it is available on system
+	return !c.usedPorts()[port] && PortFree(port)
+}
+
+// usedPorts lists the ports of all sites
+func (c *Config) usedPorts() map[int]bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	used := make(map[int]bool)
+	for _, ws := range c.Workspaces {
+		for _, site := range ws.Sites {
+			used[site.Port] = true
 		}
 	}
+	return used
+}
 
-	// Check if port is available on system
-	addr := fmt.Sprintf(":%d", port)
-	listener, err := net.Listen("tcp", addr)
+// PortFree reports whether nothing on this machine listens on port
+func PortFree(port int) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return false
 	}
@@ -192,10 +577,12 @@ func (c *Config) IsPortAvailable(port int) bool {
 }
 
 func (c *Config) GetAvailablePort() (int, error) {
-	for port := c.AppSettings.AutoPortMin; port <= c.AppSettings.AutoPortMax; port++ {
-		if c.IsPortAvailable(port) {
+	ws := c.Workspace()
+	used := c.usedPorts()
+	for port := ws.AutoPortMin; port <= ws.AutoPortMax; port++ {
+		if !used[port] && PortFree(port) {
 			return port, nil
 		}
 	}
-	return 0, fmt.Errorf("no available ports in range %d-%d", c.AppSettings.AutoPortMin, c.AppSettings.AutoPortMax)
+	return 0, fmt.Errorf("no available ports in range %d-%d", ws.AutoPortMin, ws.AutoPortMax)
 }
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRemoveBrokenSites(t *testing.T) {
+	dir := t.TempDir()
+	SetPath(filepath.Join(dir, "config.json"))
+	t.Cleanup(func() { SetPath("") })
+
+	cfg := NewDefault()
+	cfg.Workspaces[0].Sites = []Site{
+		{ID: "a", Name: "Good", Port: 8123, Folder: dir, EntryFile: "index.html"},
+		{ID: "b", Name: "No Port", Folder: dir, EntryFile: "index.html"},
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load()
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || !invalid.HasErrors() {
+		t.Fatalf("Load = %v, want a validation error", err)
+	}
+
+	// Another instance breaks one more site and adds a good one
+	cfg.Workspaces[0].Sites = append(cfg.Workspaces[0].Sites,
+		Site{ID: "c", Name: "Good", Port: 8124, Folder: dir, EntryFile: "index.html"},
+		Site{ID: "d", Name: "Added", Port: 8125, Folder: dir, EntryFile: "index.html"},
+	)
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := loaded.RemoveBrokenSites()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"No Port", "Good"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("removed %v, want %v", names, want)
+	}
+
+	after, err := Load()
+	if errors.As(err, &invalid) && invalid.HasErrors() || after == nil {
+		t.Fatalf("Load after removing = %v", err)
+	}
+	var ids []string
+	for _, site := range after.Sites() {
+		ids = append(ids, site.ID)
+	}
+	if want := []string{"a", "d"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("sites left %v, want %v", ids, want)
+	}
+}
+
+func TestLoadAddsMissingIDs(t *testing.T) {
+	dir := t.TempDir()
+	SetPath(filepath.Join(dir, "config.json"))
+	t.Cleanup(func() { SetPath("") })
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	site := func(id, name string, port int, extra string) string {
+		idField := ""
+		if id != "" {
+			idField = fmt.Sprintf(`"id": %q, `, id)
+		}
+		return fmt.Sprintf(`{%s"name": %q, "port": %d, "folder": %q, "entryFile": "index.html"%s}`,
+			idField, name, port, filepath.ToSlash(dir), extra)
+	}
+	config := func(sites ...string) string {
+		return fmt.Sprintf(`{"version": %d, "activeWorkspace": "Default", "workspaces": [
+			{"name": "Default", "autoPortMin": 8000, "autoPortMax": 9000, "sites": [%s]}]}`,
+			CurrentVersion, strings.Join(sites, ", "))
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		rewrite bool
+		warns   bool
+	}{
+		{
+			name:    "missing ID",
+			data:    config(site("a", "A", 8123, ""), site("", "B", 8124, "")),
+			rewrite: true,
+		},
+		{
+			name:    "duplicate ID",
+			data:    config(site("a", "A", 8123, ""), site("a", "B", 8124, "")),
+			rewrite: true,
+		},
+		{
+			name: "all IDs set",
+			data: config(site("a", "A", 8123, ""), site("b", "B", 8124, "")),
+		},
+		{
+			name:  "unknown field is kept",
+			data:  config(site("a", "A", 8123, ""), site("", "B", 8124, `, "entryfiel": "x.html"`)),
+			warns: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(Path(), []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load()
+			var invalid *ValidationError
+			if errors.As(err, &invalid) != tt.warns || (err != nil && invalid == nil) {
+				t.Fatalf("Load = %v, want warnings: %v", err, tt.warns)
+			}
+
+			ids := make(map[string]bool)
+			for _, s := range cfg.Sites() {
+				if s.ID == "" || ids[s.ID] {
+					t.Errorf("site %s has ID %q, want a unique one", s.Name, s.ID)
+				}
+				ids[s.ID] = true
+			}
+
+			data, err := os.ReadFile(Path())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.rewrite {
+				if string(data) != tt.data {
+					t.Errorf("config rewritten to\n%s", data)
+				}
+				return
+			}
+			again, err := Load()
+			if err != nil {
+				t.Fatalf("second Load = %v", err)
+			}
+			for _, s := range again.Sites() {
+				if !ids[s.ID] {
+					t.Errorf("site %s has ID %q after reloading, want the one saved before", s.Name, s.ID)
+				}
+			}
+		})
+	}
+}
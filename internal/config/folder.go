@@ -0,0 +1,115 @@
+package config
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Site folders are stored OS-neutral: forward slashes, cleaned, and
+// relative to the config directory whenever they live inside it, so a
+// config.json can be shared between Windows, Linux and macOS.
+
+// NormalizeFolder converts folder to its stored form. Relative folders are
+// taken to be relative to base already; absolute ones inside base are made
+// relative to it.
+func NormalizeFolder(folder, base string) string {
+	folder = strings.TrimSpace(folder)
+	if folder == "" {
+		return ""
+	}
+
+	p := cleanSlash(folder)
+	if !isAbsPortable(p) {
+		return p
+	}
+
+	if base != "" {
+		if rel, ok := relativeTo(p, cleanSlash(base)); ok {
+			return rel
+		}
+	}
+	return p
+}
+
+// ResolveFolder turns a stored folder into a path for this OS, joining
+// relative folders onto base
+func ResolveFolder(folder, base string) string {
+	if folder == "" {
+		return ""
+	}
+
+	p := cleanSlash(folder)
+	if !isAbsPortable(p) && base != "" {
+		p = path.Join(cleanSlash(base), p)
+	}
+	return filepath.FromSlash(p)
+}
+
+// FolderPath is the site folder as a usable OS path
+func (s Site) FolderPath() string {
+	base := s.base
+	if base == "" {
+		base = Dir()
+	}
+	return ResolveFolder(s.Folder, base)
+}
+
+// LogsPath is the folder the site's logs are written to
+func (s Site) LogsPath() string {
+	if s.LogsFolder != "" {
+		return ResolveFolder(s.LogsFolder, Dir())
+	}
+	return filepath.Join(s.FolderPath(), "logs")
+}
+
+// cleanSlash converts either separator to "/" and cleans the result,
+// keeping the leading "//" of UNC paths
+func cleanSlash(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	unc := strings.HasPrefix(p, "//")
+
+	p = path.Clean(p)
+	if unc && !strings.HasPrefix(p, "//") {
+		p = "/" + p
+	}
+
+	// Upper-case drive letters so C:/x and c:/x compare equal
+	if hasDrive(p) {
+		p = strings.ToUpper(p[:1]) + p[1:]
+	}
+	return p
+}
+
+// isAbsPortable recognises absolute paths of every OS regardless of the
+// one we run on: /x, //server/share and C:/x
+func isAbsPortable(p string) bool {
+	return strings.HasPrefix(p, "/") || (hasDrive(p) && len(p) >= 3 && p[2] == '/')
+}
+
+func hasDrive(p string) bool {
+	if len(p) < 2 || p[1] != ':' {
+		return false
+	}
+	c := p[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// relativeTo returns p relative to base when p is base or inside it
+func relativeTo(p, base string) (string, bool) {
+	// Windows paths compare case-insensitively
+	equal := func(a, b string) bool { return a == b }
+	if hasDrive(p) || strings.HasPrefix(p, "//") {
+		equal = strings.EqualFold
+	}
+
+	if equal(p, base) {
+		return ".", true
+	}
+
+	prefix := strings.TrimSuffix(base, "/") + "/"
+	if len(p) > len(prefix) && equal(p[:len(prefix)], prefix) {
+		return p[len(prefix):], true
+	}
+	return "", false
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeFolder(t *testing.T) {
+	tests := []struct {
+		name   string
+		folder string
+		base   string
+		want   string
+	}{
+		{"empty", "", "/home/u/.config/shinobi", ""},
+		{"windows relative", `sites\sa`, "/home/u/.config/shinobi", "sites/sa"},
+		{"posix relative", "sites/sa", `C:\Users\u\AppData\Roaming\shinobi`, "sites/sa"},
+		{"mixed separators", `sites/docs\v2\`, "", "sites/docs/v2"},
+		{"dot segments", "./sites/../sites/sa/", "", "sites/sa"},
+		{"posix inside base", "/home/u/.config/shinobi/sites/sa", "/home/u/.config/shinobi", "sites/sa"},
+		{"posix base itself", "/home/u/.config/shinobi", "/home/u/.config/shinobi/", "."},
+		{"posix outside base", "/srv/www/dist", "/home/u/.config/shinobi", "/srv/www/dist"},
+		{"posix sibling prefix", "/home/u/.config/shinobi-old/sa", "/home/u/.config/shinobi", "/home/u/.config/shinobi-old/sa"},
+		{"windows inside base", `C:\Users\u\AppData\Roaming\shinobi\sites\sa`, `C:\Users\u\AppData\Roaming\shinobi`, "sites/sa"},
+		{"windows drive case", `c:\Users\U\AppData\Roaming\Shinobi\sites\sa`, `C:\Users\u\AppData\Roaming\shinobi`, "sites/sa"},
+		{"windows outside base", `D:\work\site`, `C:\Users\u\AppData\Roaming\shinobi`, "D:/work/site"},
+		{"windows drive lower", `d:\work\site`, "", "D:/work/site"},
+		{"unc path", `\\server\share\site`, `C:\Users\u`, "//server/share/site"},
+		{"unc inside base", `\\server\share\cfg\sites\a`, `\\server\share\cfg`, "sites/a"},
+		{"surrounding space", "  sites/sa  ", "", "sites/sa"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeFolder(tt.folder, tt.base); got != tt.want {
+				t.Errorf("NormalizeFolder(%q, %q) = %q, want %q", tt.folder, tt.base, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveFolder(t *testing.T) {
+	tests := []struct {
+		name   string
+		folder string
+		base   string
+		want   string
+	}{
+		{"empty", "", "/cfg", ""},
+		{"relative", "sites/sa", "/home/u/.config/shinobi", "/home/u/.config/shinobi/sites/sa"},
+		{"relative with backslashes", `sites\sa`, "/cfg", "/cfg/sites/sa"},
+		{"base itself", ".", "/cfg", "/cfg"},
+		{"absolute posix", "/srv/www", "/cfg", "/srv/www"},
+		{"absolute windows", "D:/work/site", "/cfg", "D:/work/site"},
+		{"unc", "//server/share/site", "/cfg", "//server/share/site"},
+		{"windows base", "sites/sa", `C:\cfg`, "C:/cfg/sites/sa"},
+		{"no base", "sites/sa", "", "sites/sa"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := filepath.FromSlash(tt.want)
+			if got := ResolveFolder(tt.folder, tt.base); got != want {
+				t.Errorf("ResolveFolder(%q, %q) = %q, want %q", tt.folder, tt.base, got, want)
+			}
+		})
+	}
+}
+
+func TestFolderRoundTrip(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "cfg")
+	folders := []string{
+		filepath.Join(base, "sites", "a"),
+		filepath.Join(base, "nested", "deeper", "b"),
+		base,
+	}
+
+	for _, folder := range folders {
+		stored := NormalizeFolder(folder, base)
+		if isAbsPortable(stored) {
+			t.Errorf("NormalizeFolder(%q) = %q, want a relative path", folder, stored)
+		}
+		if got := ResolveFolder(stored, base); got != filepath.Clean(folder) {
+			t.Errorf("ResolveFolder(NormalizeFolder(%q)) = %q", folder, got)
+		}
+	}
+}
+
+func TestIsAbsPortable(t *testing.T) {
+	tests := map[string]bool{
+		"/usr/share":       true,
+		"//server/share":   true,
+		"C:/Users":         true,
+		"c:/":              true,
+		"C:relative":       false,
+		"sites/sa":         false,
+		".":                false,
+		"1:/not-a-drive":   false,
+		"../outside/base":  false,
+		"sites:colon/file": false,
+	}
+
+	for p, want := range tests {
+		if got := isAbsPortable(p); got != want {
+			t.Errorf("isAbsPortable(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestMigrateFolders(t *testing.T) {
+	base := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(base, "sites", "sa"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(`{
+		"version": 1,
+		"sites": [
+			{"name": "windows", "folder": "sites\\sa", "port": 8001, "entryFile": "index.html"},
+			{"name": "absolute", "folder": "` + filepath.ToSlash(filepath.Join(base, "sites", "sa")) + `", "port": 8002, "entryFile": "index.html"},
+			{"name": "elsewhere", "folder": "D:\\work\\site", "port": 8003, "entryFile": "index.html"}
+		],
+		"appSettings": {"autoPortMin": 8000, "autoPortMax": 9000}
+	}`)
+
+	version, upgraded, err := migrate(data, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 || upgraded == nil {
+		t.Fatalf("migrate returned version %d, upgraded %v", version, upgraded != nil)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(upgraded, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("version = %d, want %d", cfg.Version, CurrentVersion)
+	}
+
+	if len(cfg.Workspaces) != 1 || cfg.Workspaces[0].AutoPortMin != 8000 {
+		t.Fatalf("workspaces = %+v, want the sites moved into one default workspace", cfg.Workspaces)
+	}
+
+	want := []string{"sites/sa", "sites/sa", "D:/work/site"}
+	for i, site := range cfg.Workspaces[0].Sites {
+		if site.Folder != want[i] {
+			t.Errorf("site %q folder = %q, want %q", site.Name, site.Folder, want[i])
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// NewSiteID returns a random site ID
+func NewSiteID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// newSiteID returns an ID no site in the config has
+func (c *Config) newSiteID() string {
+	for {
+		id := NewSiteID()
+		if c.getSite(id) == nil {
+			return id
+		}
+	}
+}
+
+// ensureIDs gives sites without an ID, or with one already used by an
+// earlier site, a new one. It reports whether anything changed.
+func (c *Config) ensureIDs() bool {
+	changed := false
+	seen := make(map[string]bool)
+	for w := range c.Workspaces {
+		ws := &c.Workspaces[w]
+		for i := range ws.Sites {
+			site := &ws.Sites[i]
+			if site.ID == "" || seen[site.ID] {
+				site.ID = c.newSiteID()
+				changed = true
+			}
+			seen[site.ID] = true
+		}
+	}
+	return changed
+}
+
+// ensureRawIDs does what ensureIDs does on the raw JSON of a config, so
+// fields this build doesn't know survive. It returns nil when every site
+// already has an ID of its own.
+func ensureRawIDs(data []byte) ([]byte, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	var sites []map[string]interface{}
+	workspaces, _ := raw["workspaces"].([]interface{})
+	for _, w := range workspaces {
+		if ws, ok := w.(map[string]interface{}); ok {
+			sites = append(sites, rawSites(ws)...)
+		}
+	}
+
+	taken := make(map[string]bool)
+	for _, site := range sites {
+		if id, ok := site["id"].(string); ok {
+			taken[id] = true
+		}
+	}
+
+	changed := false
+	seen := make(map[string]bool)
+	for _, site := range sites {
+		id, _ := site["id"].(string)
+		if id == "" || seen[id] {
+			for id = NewSiteID(); taken[id]; id = NewSiteID() {
+			}
+			taken[id] = true
+			site["id"] = id
+			changed = true
+		}
+		seen[id] = true
+	}
+	if !changed {
+		return nil, nil
+	}
+	return json.MarshalIndent(raw, "", "  ")
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CurrentVersion is the config schema version this build writes
+const CurrentVersion = 4
+
+// A migration upgrades the raw JSON of a config from version-1 to version.
+// Working on the raw document lets migrations see fields the current
+// structs no longer have. base is the directory of the config file.
+type migration struct {
+	version     int
+	description string
+	apply       func(raw map[string]interface{}, base string) error
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "add schema version and default app settings",
+		apply: func(raw map[string]interface{}, base string) error {
+			if _, ok := raw["sites"].([]interface{}); !ok {
+				raw["sites"] = []interface{}{}
+			}
+			settings, ok := raw["appSettings"].(map[string]interface{})
+			if !ok {
+				settings = map[string]interface{}{}
+				raw["appSettings"] = settings
+			}
+			if _, ok := settings["autoPortMin"]; !ok {
+				settings["autoPortMin"] = 8000
+			}
+			if _, ok := settings["autoPortMax"]; !ok {
+				settings["autoPortMax"] = 9000
+			}
+			return nil
+		},
+	},
+	{
+		version:     2,
+		description: "store site folders OS-neutral and relative to the config directory",
+		apply: func(raw map[string]interface{}, base string) error {
+			for _, site := range rawSites(raw) {
+				folder, ok := site["folder"].(string)
+				if !ok || folder == "" {
+					continue
+				}
+
+				p := cleanSlash(folder)
+				if !isAbsPortable(p) && !exists(ResolveFolder(p, base)) {
+					// Older builds resolved relative folders against the
+					// working directory
+					if abs, err := filepath.Abs(filepath.FromSlash(p)); err == nil && exists(abs) {
+						p = abs
+					}
+				}
+				site["folder"] = NormalizeFolder(p, base)
+			}
+			return nil
+		},
+	},
+	{
+		version:     3,
+		description: "move sites and the auto port range into a default workspace",
+		apply: func(raw map[string]interface{}, base string) error {
+			workspace := map[string]interface{}{
+				"name":        DefaultWorkspace,
+				"autoPortMin": 8000,
+				"autoPortMax": 9000,
+				"sites":       raw["sites"],
+			}
+			if settings, ok := raw["appSettings"].(map[string]interface{}); ok {
+				for _, key := range []string{"autoPortMin", "autoPortMax"} {
+					if v, ok := settings[key]; ok {
+						workspace[key] = v
+						delete(settings, key)
+					}
+				}
+			}
+			if _, ok := workspace["sites"].([]interface{}); !ok {
+				workspace["sites"] = []interface{}{}
+			}
+
+			delete(raw, "sites")
+			raw["workspaces"] = []interface{}{workspace}
+			raw["activeWorkspace"] = DefaultWorkspace
+			return nil
+		},
+	},
+	{
+		version:     4,
+		description: "give every site an ID and make names unique within a workspace",
+		apply: func(raw map[string]interface{}, base string) error {
+			workspaces, _ := raw["workspaces"].([]interface{})
+			for _, w := range workspaces {
+				ws, ok := w.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				names := make(map[string]bool)
+				for _, site := range rawSites(ws) {
+					site["id"] = NewSiteID()
+
+					name, _ := site["name"].(string)
+					name = strings.TrimSpace(name)
+					if name == "" {
+						name = "site"
+					}
+					unique := name
+					for i := 2; names[strings.ToLower(unique)]; i++ {
+						unique = fmt.Sprintf("%s-%d", name, i)
+					}
+					names[strings.ToLower(unique)] = true
+					site["name"] = unique
+				}
+			}
+			return nil
+		},
+	},
+}
+
+func rawSites(raw map[string]interface{}) []map[string]interface{} {
+	list, _ := raw["sites"].([]interface{})
+	sites := make([]map[string]interface{}, 0, len(list))
+	for _, s := range list {
+		if site, ok := s.(map[string]interface{}); ok {
+			sites = append(sites, site)
+		}
+	}
+	return sites
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// migrate upgrades data to CurrentVersion. It returns the version the data
+// was at, and the upgraded data when anything changed.
+func migrate(data []byte, base string) (int, []byte, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return 0, nil, err
+	}
+
+	version := 0
+	if v, ok := raw["version"].(float64); ok {
+		version = int(v)
+	}
+
+	if version > CurrentVersion {
+		return version, nil, fmt.Errorf("config version %d is newer than this build supports (%d)", version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return version, nil, nil
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if err := m.apply(raw, base); err != nil {
+			return version, nil, fmt.Errorf("migration to version %d (%s) failed: %v", m.version, m.description, err)
+		}
+		raw["version"] = m.version
+	}
+
+	upgraded, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return version, nil, err
+	}
+	return version, upgraded, nil
+}
+
+// backup copies the config file aside before it is rewritten by a migration
+func backup(path string, data []byte, version int) (string, error) {
+	name := fmt.Sprintf("%s.v%d-%s.bak", filepath.Base(path), version, time.Now().Format("2006-01-02_15-04-05"))
+	backupPath := filepath.Join(filepath.Dir(path), name)
+	return backupPath, os.WriteFile(backupPath, data, 0644)
+}
+
+// importLegacy copies a config.json from the working directory, where older
+// builds kept it, to the user config directory. Relative site folders were
+// relative to the working directory, so they are made absolute.
+func importLegacy(path string) ([]byte, error) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	for _, site := range rawSites(raw) {
+		folder, ok := site["folder"].(string)
+		if !ok || folder == "" {
+			continue
+		}
+		if p := cleanSlash(folder); !isAbsPortable(p) {
+			if abs, err := filepath.Abs(filepath.FromSlash(p)); err == nil {
+				site["folder"] = abs
+			}
+		}
+	}
+
+	imported, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, imported, 0644); err != nil {
+		return nil, err
+	}
+	return imported, nil
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of desktop notifications, as listed in Notifications.Muted
+const (
+	NotifyCrash       = "crash"
+	NotifyFailedStart = "failedStart"
+	NotifyRestart     = "restart"
+	NotifyErrors      = "errors"
+)
+
+// NotifyKinds lists every kind of notification
+var NotifyKinds = []string{NotifyCrash, NotifyFailedStart, NotifyRestart, NotifyErrors}
+
+const (
+	DefaultNotifyPerHour   = 10
+	DefaultErrorsPerMinute = 10
+	DefaultErrorMinutes    = 5
+)
+
+// Notifications chooses which desktop notifications are sent and when
+type Notifications struct {
+	// Kinds not to notify about
+	Muted []string `json:"muted,omitempty"`
+
+	// Local times like "22:00" between which nothing is sent. Until may be
+	// earlier than From to span midnight.
+	QuietFrom  string `json:"quietFrom,omitempty"`
+	QuietUntil string `json:"quietUntil,omitempty"`
+
+	// At most this many notifications an hour; 0 uses DefaultNotifyPerHour
+	MaxPerHour int `json:"maxPerHour,omitempty"`
+
+	// A site has an error spike when it answers with at least
+	// ErrorsPerMinute server errors every minute for ErrorMinutes minutes;
+	// 0 uses the defaults
+	ErrorsPerMinute int `json:"errorsPerMinute,omitempty"`
+	ErrorMinutes    int `json:"errorMinutes,omitempty"`
+}
+
+// Enabled reports whether notifications of the kind are sent
+func (n Notifications) Enabled(kind string) bool {
+	for _, muted := range n.Muted {
+		if muted == kind {
+			return false
+		}
+	}
+	return true
+}
+
+// Quiet reports whether t falls within the quiet hours
+func (n Notifications) Quiet(t time.Time) bool {
+	from, err1 := ParseClock(n.QuietFrom)
+	until, err2 := ParseClock(n.QuietUntil)
+	if err1 != nil || err2 != nil || from == until {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if from < until {
+		return now >= from && now < until
+	}
+	return now >= from || now < until
+}
+
+func (n Notifications) PerHour() int {
+	if n.MaxPerHour <= 0 {
+		return DefaultNotifyPerHour
+	}
+	return n.MaxPerHour
+}
+
+func (n Notifications) SpikeRate() int {
+	if n.ErrorsPerMinute <= 0 {
+		return DefaultErrorsPerMinute
+	}
+	return n.ErrorsPerMinute
+}
+
+func (n Notifications) SpikeMinutes() int {
+	if n.ErrorMinutes <= 0 {
+		return DefaultErrorMinutes
+	}
+	return n.ErrorMinutes
+}
+
+// ParseClock turns "HH:MM" into minutes since midnight
+func ParseClock(s string) (int, error) {
+	hours, minutes, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	h, err1 := strconv.Atoi(hours)
+	m, err2 := strconv.Atoi(minutes)
+	if err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return h*60 + m, nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuiet(t *testing.T) {
+	tests := []struct {
+		from, until string
+		hour, min   int
+		want        bool
+	}{
+		{"22:00", "08:00", 21, 59, false},
+		{"22:00", "08:00", 22, 0, true},
+		{"22:00", "08:00", 3, 0, true},
+		{"22:00", "08:00", 8, 0, false},
+		{"09:00", "17:00", 12, 0, true},
+		{"09:00", "17:00", 17, 0, false},
+		{"09:00", "09:00", 9, 0, false},
+		{"", "", 3, 0, false},
+		{"bad", "08:00", 3, 0, false},
+	}
+
+	for _, tt := range tests {
+		n := Notifications{QuietFrom: tt.from, QuietUntil: tt.until}
+		at := time.Date(2026, 3, 2, tt.hour, tt.min, 0, 0, time.Local)
+		if got := n.Quiet(at); got != tt.want {
+			t.Errorf("Quiet(%s-%s) at %02d:%02d = %v, want %v", tt.from, tt.until, tt.hour, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"00:00", 0, false},
+		{"08:30", 510, false},
+		{" 23:59 ", 1439, false},
+		{"7:05", 425, false},
+		{"24:00", 0, true},
+		{"12:60", 0, true},
+		{"1200", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseClock(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseClock(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const appDirName = "shinobi-webserver"
+
+// Set through SetPath, e.g. from --config; empty means DefaultPath
+var configPath string
+
+// DefaultPath is config.json in the OS user config directory
+// (~/.config/shinobi-webserver on Linux, %AppData% on Windows,
+// ~/Library/Application Support on macOS)
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		// No home directory to speak of, fall back to the working directory
+		return configFile
+	}
+	return filepath.Join(dir, appDirName, configFile)
+}
+
+// SetPath overrides where the config is loaded from and saved to
+func SetPath(path string) {
+	if path == "" {
+		configPath = ""
+		return
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	configPath = path
+}
+
+// Path is the config file in use
+func Path() string {
+	if configPath != "" {
+		return configPath
+	}
+	return DefaultPath()
+}
+
+// Dir holds the config file and app-managed data next to it
+func Dir() string {
+	return filepath.Dir(Path())
+}
+
+// LogsDir holds the logs of sites whose folders shouldn't get a logs
+// folder, such as build output
+func LogsDir() string {
+	return filepath.Join(Dir(), "logs")
+}
+
+// SitesDir is where new sites are created by default
+func SitesDir() string {
+	return filepath.Join(Dir(), "sites")
+}
@@ -0,0 +1,378 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"shinobi-webserver/internal/netsim"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is one finding of the validation pass. Path is a JSON path into
+// the config file, e.g. $.workspaces[0].sites[1].port.
+type Problem struct {
+	Path     string
+	Message  string
+	Severity Severity
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// ValidationError is returned by Load alongside the config when the file
+// parsed but has problems, so callers can offer a repair instead of
+// discarding it
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = fmt.Sprintf("%s (%s)", p, p.Severity)
+	}
+	return "invalid config:\n" + strings.Join(lines, "\n")
+}
+
+// HasErrors reports whether any problem is more than a warning
+func (e *ValidationError) HasErrors() bool {
+	for _, p := range e.Problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// SiteRef locates a site by workspace and site index
+type SiteRef struct {
+	Workspace int
+	Site      int
+}
+
+// SitesWithErrors lists the sites that have error-level problems, in
+// config order
+func (e *ValidationError) SitesWithErrors() []SiteRef {
+	seen := make(map[SiteRef]bool)
+	var refs []SiteRef
+	for _, p := range e.Problems {
+		var ref SiteRef
+		if p.Severity != SeverityError {
+			continue
+		}
+		if _, err := fmt.Sscanf(p.Path, "$.workspaces[%d].sites[%d]", &ref.Workspace, &ref.Site); err == nil && !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Workspace != refs[j].Workspace {
+			return refs[i].Workspace < refs[j].Workspace
+		}
+		return refs[i].Site < refs[j].Site
+	})
+	return refs
+}
+
+// SyntaxError locates a JSON syntax error in the config file
+type SyntaxError struct {
+	Path   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %v", e.Path, e.Line, e.Column, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+func newSyntaxError(path string, data []byte, err error) error {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	line, col := 1, 1
+	for i := int64(0); i < offset && i < int64(len(data)); i++ {
+		if data[i] == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return &SyntaxError{Path: path, Line: line, Column: col, Err: err}
+}
+
+// Validate checks the config for problems that would break sites.
+// data is the raw file, used to find unknown fields; it may be nil.
+func (c *Config) Validate(data []byte) []Problem {
+	var problems []Problem
+	add := func(severity Severity, path, format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			Path:     path,
+			Message:  fmt.Sprintf(format, args...),
+			Severity: severity,
+		})
+	}
+
+	if data != nil {
+		var raw interface{}
+		if err := json.Unmarshal(data, &raw); err == nil {
+			for _, p := range unknownFields(raw, reflect.TypeOf(Config{}), "$") {
+				add(SeverityWarning, p, "unknown field")
+			}
+		}
+	}
+
+	if len(c.Workspaces) == 0 {
+		add(SeverityError, "$.workspaces", "no workspaces")
+	} else if c.ActiveWorkspace != "" && c.findWorkspace(c.ActiveWorkspace) == nil {
+		add(SeverityWarning, "$.activeWorkspace", "unknown workspace %q", c.ActiveWorkspace)
+	}
+
+	// Ports must be unique across workspaces, their sites can run side by side
+	ports := make(map[int]string)
+	workspaces := make(map[string]int)
+	for w := range c.Workspaces {
+		ws := &c.Workspaces[w]
+		wsPath := fmt.Sprintf("$.workspaces[%d]", w)
+
+		wsName := strings.TrimSpace(ws.Name)
+		if wsName == "" {
+			add(SeverityError, wsPath+".name", "workspace name is empty")
+		} else if first, dup := workspaces[strings.ToLower(wsName)]; dup {
+			add(SeverityError, wsPath+".name", "duplicate workspace name %q (also $.workspaces[%d])", ws.Name, first)
+		} else {
+			workspaces[strings.ToLower(wsName)] = w
+		}
+
+		if ws.Folder != "" && !exists(ws.Base()) {
+			add(SeverityWarning, wsPath+".folder", "folder %s does not exist", ws.Base())
+		}
+
+		if ws.AutoPortMin < 1 || ws.AutoPortMin > 65535 {
+			add(SeverityError, wsPath+".autoPortMin", "port %d is out of range 1-65535", ws.AutoPortMin)
+		}
+		if ws.AutoPortMax < 1 || ws.AutoPortMax > 65535 {
+			add(SeverityError, wsPath+".autoPortMax", "port %d is out of range 1-65535", ws.AutoPortMax)
+		}
+		if ws.AutoPortMin >= ws.AutoPortMax {
+			add(SeverityError, wsPath+".autoPortMax", "must be greater than autoPortMin (%d)", ws.AutoPortMin)
+		}
+
+		problems = append(problems, c.validateSites(ws, wsPath, ports)...)
+	}
+
+	settings := c.AppSettings
+	for i := range settings.NetworkProfiles {
+		if err := settings.NetworkProfiles[i].Validate(); err != nil {
+			add(SeverityError, fmt.Sprintf("$.appSettings.networkProfiles[%d]", i), "%v", err)
+		}
+	}
+
+	notifications := settings.Notifications
+	for i, kind := range notifications.Muted {
+		known := false
+		for _, k := range NotifyKinds {
+			known = known || k == kind
+		}
+		if !known {
+			add(SeverityWarning, fmt.Sprintf("$.appSettings.notifications.muted[%d]", i), "unknown notification kind %q", kind)
+		}
+	}
+	if (notifications.QuietFrom == "") != (notifications.QuietUntil == "") {
+		add(SeverityWarning, "$.appSettings.notifications", "quiet hours need both quietFrom and quietUntil")
+	}
+	if _, err := ParseClock(notifications.QuietFrom); notifications.QuietFrom != "" && err != nil {
+		add(SeverityWarning, "$.appSettings.notifications.quietFrom", "%v", err)
+	}
+	if _, err := ParseClock(notifications.QuietUntil); notifications.QuietUntil != "" && err != nil {
+		add(SeverityWarning, "$.appSettings.notifications.quietUntil", "%v", err)
+	}
+
+	return problems
+}
+
+// validateSites checks the sites of one workspace. ports maps the ports
+// seen so far to the path of the site using them.
+func (c *Config) validateSites(ws *Workspace, wsPath string, ports map[int]string) []Problem {
+	var problems []Problem
+	add := func(severity Severity, path, format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			Path:     path,
+			Message:  fmt.Sprintf(format, args...),
+			Severity: severity,
+		})
+	}
+
+	names := make(map[string]int)
+	for i, site := range ws.Sites {
+		path := fmt.Sprintf("%s.sites[%d]", wsPath, i)
+
+		name := strings.TrimSpace(site.Name)
+		if name == "" {
+			add(SeverityError, path+".name", "site name is empty")
+		} else if first, dup := names[strings.ToLower(name)]; dup {
+			add(SeverityError, path+".name", "duplicate site name %q (also %s.sites[%d])", site.Name, wsPath, first)
+		} else {
+			names[strings.ToLower(name)] = i
+		}
+
+		if site.Port < 1 || site.Port > 65535 {
+			add(SeverityError, path+".port", "port %d is out of range 1-65535", site.Port)
+		} else if first, dup := ports[site.Port]; dup {
+			add(SeverityError, path+".port", "port %d is also used by %s", site.Port, first)
+		} else {
+			ports[site.Port] = path
+		}
+
+		folderOK := false
+		if strings.TrimSpace(site.Folder) == "" {
+			add(SeverityError, path+".folder", "folder is empty")
+		} else if info, err := os.Stat(site.FolderPath()); err != nil {
+			add(SeverityWarning, path+".folder", "folder %s does not exist", site.FolderPath())
+		} else if !info.IsDir() {
+			add(SeverityError, path+".folder", "%s is not a folder", site.FolderPath())
+		} else {
+			folderOK = true
+		}
+
+		entry := site.EntryFile
+		switch {
+		case entry == "":
+			add(SeverityError, path+".entryFile", "entry file is empty")
+		case filepath.IsAbs(entry) || strings.HasPrefix(entry, "/") || strings.HasPrefix(entry, "\\"):
+			add(SeverityError, path+".entryFile", "entry file %q must be relative to the site folder", entry)
+		case hasParentRef(entry):
+			add(SeverityError, path+".entryFile", "entry file %q points outside the site folder", entry)
+		case folderOK:
+			if _, err := os.Stat(filepath.Join(site.FolderPath(), filepath.FromSlash(entry))); err != nil {
+				add(SeverityWarning, path+".entryFile", "entry file %s does not exist in the site folder", entry)
+			}
+		}
+
+		if site.NetworkProfile != "" && netsim.Find(site.NetworkProfile, c.AppSettings.NetworkProfiles) == nil {
+			add(SeverityError, path+".networkProfile", "unknown network profile %q", site.NetworkProfile)
+		}
+
+		for d, id := range site.DependsOn {
+			switch {
+			case id == site.ID:
+				add(SeverityWarning, fmt.Sprintf("%s.dependsOn[%d]", path, d), "site depends on itself")
+			case c.getSite(id) == nil:
+				add(SeverityWarning, fmt.Sprintf("%s.dependsOn[%d]", path, d), "no site has the ID %q", id)
+			}
+		}
+	}
+
+	return problems
+}
+
+func hasParentRef(p string) bool {
+	for _, part := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownFields walks raw JSON alongside the struct type it decodes into
+// and returns the paths of keys the type has no field for
+func unknownFields(raw interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var unknown []string
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		if t.Kind() == reflect.Map {
+			for key, val := range v {
+				unknown = append(unknown, unknownFields(val, t.Elem(), path+"."+key)...)
+			}
+			break
+		}
+		if t.Kind() != reflect.Struct {
+			break
+		}
+
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			field, ok := fields[key]
+			if !ok {
+				// encoding/json matches keys case-insensitively
+				for name, f := range fields {
+					if strings.EqualFold(name, key) {
+						field, ok = f, true
+						break
+					}
+				}
+			}
+			if !ok {
+				unknown = append(unknown, path+"."+key)
+				continue
+			}
+			unknown = append(unknown, unknownFields(v[key], field.Type, path+"."+key)...)
+		}
+
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			break
+		}
+		for i, item := range v {
+			unknown = append(unknown, unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return unknown
+}
+
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		fields[name] = f
+	}
+	return fields
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"shinobi-webserver/internal/filelock"
+)
+
+// Editors and atomic saves produce bursts of events for one change
+const watchDebounce = 250 * time.Millisecond
+
+// Watch reloads the config when the file is changed by anything other than
+// this process, then calls onChange. If the changed file can't be used,
+// onChange gets the error and the config is left as it was. The returned
+// function stops watching.
+func (c *Config) Watch(onChange func(err error)) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// Watch the directory: atomic saves replace the file, which would end
+	// a watch on the file itself
+	path := Path()
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case <-done:
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != path || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(watchDebounce, func() {
+					changed, err := c.reloadExternal()
+					if changed || err != nil {
+						onChange(err)
+					}
+				})
+
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	stop := func() {
+		close(done)
+		watcher.Close()
+	}
+	return stop, nil
+}
+
+// reloadExternal reloads the file, reporting whether it held anything new
+func (c *Config) reloadExternal() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lock, err := filelock.Acquire(lockPath())
+	if err != nil {
+		return false, err
+	}
+	defer lock.Release()
+
+	before := c.saved
+	if err := c.reload(); err != nil {
+		return false, err
+	}
+	return string(before) != string(c.saved), nil
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DefaultWorkspace = "Default"
+
+// Workspace is an independent collection of sites with its own port range
+type Workspace struct {
+	Name string `json:"name"`
+
+	// Base for the workspace's relative site folders and where new sites
+	// are created; empty means the config directory
+	Folder string `json:"folder,omitempty"`
+
+	AutoPortMin int    `json:"autoPortMin"`
+	AutoPortMax int    `json:"autoPortMax"`
+	Sites       []Site `json:"sites"`
+}
+
+func newWorkspace(name string) Workspace {
+	return Workspace{
+		Name:        name,
+		AutoPortMin: 8000,
+		AutoPortMax: 9000,
+		Sites:       []Site{},
+	}
+}
+
+// Base is the directory relative site folders are resolved against
+func (w *Workspace) Base() string {
+	if w.Folder == "" {
+		return Dir()
+	}
+	return ResolveFolder(w.Folder, Dir())
+}
+
+// SitesDir is where new sites of the workspace are created by default
+func (w *Workspace) SitesDir() string {
+	if w.Folder == "" {
+		return SitesDir()
+	}
+	return w.Base()
+}
+
+// Workspace returns a copy of the workspace this process works in
+func (c *Config) Workspace() *Workspace {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ws := c.current().copy()
+	return &ws
+}
+
+// current is the workspace this process works in, for callers holding c.mu
+func (c *Config) current() *Workspace {
+	for _, name := range []string{c.workspace, c.ActiveWorkspace} {
+		if ws := c.findWorkspace(name); ws != nil {
+			return ws
+		}
+	}
+	if len(c.Workspaces) == 0 {
+		// Only before loading; bind adds the default workspace
+		ws := newWorkspace(DefaultWorkspace)
+		return &ws
+	}
+	return &c.Workspaces[0]
+}
+
+// copy keeps the sites apart from the config's, which a reload replaces
+func (w Workspace) copy() Workspace {
+	w.Sites = append([]Site{}, w.Sites...)
+	return w
+}
+
+// Sites lists the sites of the current workspace
+func (c *Config) Sites() []Site {
+	return c.Workspace().Sites
+}
+
+// AllWorkspaces returns a copy of every workspace with its sites
+func (c *Config) AllWorkspaces() []Workspace {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	workspaces := make([]Workspace, len(c.Workspaces))
+	for i, ws := range c.Workspaces {
+		workspaces[i] = ws.copy()
+	}
+	return workspaces
+}
+
+// WorkspaceNames lists all workspaces in config order
+func (c *Config) WorkspaceNames() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, len(c.Workspaces))
+	for i, ws := range c.Workspaces {
+		names[i] = ws.Name
+	}
+	return names
+}
+
+// UseWorkspace selects the workspace for this process only, as the
+// --workspace flag does
+func (c *Config) UseWorkspace(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ws := c.findWorkspace(name)
+	if ws == nil {
+		return fmt.Errorf("unknown workspace %q", name)
+	}
+	c.workspace = ws.Name
+	return nil
+}
+
+// SwitchWorkspace selects a workspace and remembers it for the next launch
+func (c *Config) SwitchWorkspace(name string) error {
+	if err := c.UseWorkspace(name); err != nil {
+		return err
+	}
+
+	return c.update(func() error {
+		if c.findWorkspace(name) == nil {
+			return fmt.Errorf("workspace %q was removed", name)
+		}
+		c.ActiveWorkspace = c.workspace
+		return nil
+	})
+}
+
+func (c *Config) AddWorkspace(ws Workspace) error {
+	ws.Name = strings.TrimSpace(ws.Name)
+	if ws.Name == "" {
+		return fmt.Errorf("workspace name is empty")
+	}
+	if ws.AutoPortMin >= ws.AutoPortMax {
+		return fmt.Errorf("invalid port range %d-%d", ws.AutoPortMin, ws.AutoPortMax)
+	}
+	ws.Folder = NormalizeFolder(ws.Folder, Dir())
+	if ws.Sites == nil {
+		ws.Sites = []Site{}
+	}
+
+	return c.update(func() error {
+		if c.findWorkspace(ws.Name) != nil {
+			return fmt.Errorf("workspace %q already exists", ws.Name)
+		}
+		c.Workspaces = append(c.Workspaces, ws)
+		c.bind()
+		return nil
+	})
+}
+
+// UpdateWorkspace changes a workspace's name, folder and port range; its
+// sites are kept
+func (c *Config) UpdateWorkspace(name string, updated Workspace) error {
+	updated.Name = strings.TrimSpace(updated.Name)
+	if updated.Name == "" {
+		return fmt.Errorf("workspace name is empty")
+	}
+	if updated.AutoPortMin >= updated.AutoPortMax {
+		return fmt.Errorf("invalid port range %d-%d", updated.AutoPortMin, updated.AutoPortMax)
+	}
+	updated.Folder = NormalizeFolder(updated.Folder, Dir())
+
+	return c.update(func() error {
+		ws := c.findWorkspace(name)
+		if ws == nil {
+			return fmt.Errorf("unknown workspace %q", name)
+		}
+		if other := c.findWorkspace(updated.Name); other != nil && other != ws {
+			return fmt.Errorf("workspace %q already exists", updated.Name)
+		}
+
+		if strings.EqualFold(c.workspace, ws.Name) {
+			c.workspace = updated.Name
+		}
+		if strings.EqualFold(c.ActiveWorkspace, ws.Name) {
+			c.ActiveWorkspace = updated.Name
+		}
+
+		ws.Name = updated.Name
+		ws.Folder = updated.Folder
+		ws.AutoPortMin = updated.AutoPortMin
+		ws.AutoPortMax = updated.AutoPortMax
+		c.bind()
+		return nil
+	})
+}
+
+// RemoveWorkspace deletes an empty workspace; there is always at least one
+func (c *Config) RemoveWorkspace(name string) error {
+	return c.update(func() error {
+		for i, ws := range c.Workspaces {
+			if !strings.EqualFold(ws.Name, name) {
+				continue
+			}
+			if len(ws.Sites) > 0 {
+				return fmt.Errorf("workspace %q still has %d sites", ws.Name, len(ws.Sites))
+			}
+			if len(c.Workspaces) == 1 {
+				return fmt.Errorf("the last workspace can't be removed")
+			}
+			c.Workspaces = append(c.Workspaces[:i], c.Workspaces[i+1:]...)
+			return nil
+		}
+		return fmt.Errorf("unknown workspace %q", name)
+	})
+}
+
+// Names compare case-insensitively, like site names
+func (c *Config) findWorkspace(name string) *Workspace {
+	if name == "" {
+		return nil
+	}
+	for i := range c.Workspaces {
+		if strings.EqualFold(c.Workspaces[i].Name, name) {
+			return &c.Workspaces[i]
+		}
+	}
+	return nil
+}
+
+func (c *Config) ensureWorkspace() {
+	if len(c.Workspaces) == 0 {
+		c.Workspaces = []Workspace{newWorkspace(DefaultWorkspace)}
+	}
+}
+
+// bind tells every site which workspace base its folder is relative to
+func (c *Config) bind() {
+	c.ensureWorkspace()
+	c.ensureIDs()
+	for i := range c.Workspaces {
+		ws := &c.Workspaces[i]
+		base := ws.Base()
+		for j := range ws.Sites {
+			ws.Sites[j].base = base
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"shinobi-webserver/internal/links"
+	"shinobi-webserver/internal/siteconfig"
+)
+
+// Name of the redirects manifest, in the format Netlify and Cloudflare Pages read
+const RedirectsFile = "_redirects"
+
+type Redirect struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status"`
+}
+
+type BrokenLink struct {
+	Page   string `json:"page"`
+	Link   string `json:"link"`
+	Status int    `json:"status"`
+}
+
+// Failure is a URL the site couldn't render
+type Failure struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+type Report struct {
+	Target      string       `json:"target"`
+	Files       []string     `json:"files"`
+	Redirects   []Redirect   `json:"redirects"`
+	BrokenLinks []BrokenLink `json:"brokenLinks"`
+	Errors      []Failure    `json:"errors"`
+}
+
+// ErrTargetNotEmpty is returned when the target folder already has files,
+// or the target .zip exists, and overwriting wasn't allowed
+var ErrTargetNotEmpty = errors.New("export target is not empty")
+
+// Export renders everything handler serves for the site in root and writes
+// it to target, a directory or a .zip file. Every file in the folder is
+// requested through the handler, and links found in the output are
+// followed so generated pages are picked up as well. Files in an existing
+// target folder are only replaced when overwrite is set.
+func Export(handler http.Handler, root, target string, overwrite bool) (*Report, error) {
+	if err := checkTarget(root, target); err != nil {
+		return nil, err
+	}
+	if !overwrite && TargetNotEmpty(target) {
+		return nil, fmt.Errorf("%w: %s", ErrTargetNotEmpty, target)
+	}
+
+	seeds, err := seedURLs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var out writer
+	if strings.EqualFold(filepath.Ext(target), ".zip") {
+		out, err = newZipWriter(target)
+	} else {
+		out, err = newDirWriter(target)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &crawler{
+		handler: handler,
+		out:     out,
+		seen:    make(map[string]bool),
+		written: make(map[string]bool),
+		report: &Report{
+			Target:      target,
+			Files:       []string{},
+			Redirects:   []Redirect{},
+			BrokenLinks: []BrokenLink{},
+			Errors:      []Failure{},
+		},
+	}
+	for _, u := range seeds {
+		c.enqueue(u, "")
+	}
+	if err := c.run(); err != nil {
+		out.Close()
+		return nil, err
+	}
+
+	if len(c.report.Redirects) > 0 {
+		if err := out.Write(RedirectsFile, redirectsManifest(c.report.Redirects)); err != nil {
+			out.Close()
+			return nil, err
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(c.report.Files)
+	return c.report, nil
+}
+
+type queued struct {
+	url  string
+	from string
+}
+
+type crawler struct {
+	handler http.Handler
+	out     writer
+	queue   []queued
+	seen    map[string]bool
+	written map[string]bool
+	report  *Report
+}
+
+func (c *crawler) enqueue(u, from string) {
+	if c.seen[u] {
+		return
+	}
+	c.seen[u] = true
+	c.queue = append(c.queue, queued{url: u, from: from})
+}
+
+func (c *crawler) run() error {
+	for len(c.queue) > 0 {
+		q := c.queue[0]
+		c.queue = c.queue[1:]
+
+		rec, err := c.fetch(q.url)
+		if err != nil {
+			c.report.Errors = append(c.report.Errors, Failure{URL: q.url, Error: err.Error()})
+			continue
+		}
+
+		switch {
+		case rec.Code >= 300 && rec.Code < 400:
+			c.redirect(q.url, rec)
+
+		case rec.Code != http.StatusOK:
+			if q.from != "" {
+				c.report.BrokenLinks = append(c.report.BrokenLinks, BrokenLink{
+					Page:   q.from,
+					Link:   q.url,
+					Status: rec.Code,
+				})
+			}
+
+		default:
+			if err := c.save(q.url, rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fetch requests a URL path from the site. Paths are kept unescaped so
+// they name files; a handler that panics fails only that URL.
+func (c *crawler) fetch(p string) (rec *httptest.ResponseRecorder, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	req := httptest.NewRequest(http.MethodGet, (&url.URL{Path: p}).EscapedPath(), nil)
+	rec = httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, req)
+	return rec, nil
+}
+
+func (c *crawler) redirect(from string, rec *httptest.ResponseRecorder) {
+	location := rec.Header().Get("Location")
+	target, internal := links.Internal(from, location)
+	if !internal {
+		c.report.Redirects = append(c.report.Redirects, Redirect{From: from, To: location, Status: rec.Code})
+		return
+	}
+
+	// Directory slashes and index.html -> ./ are handled by every static
+	// host itself, so those only need the target exported
+	if target != from+"/" && target+"index.html" != from {
+		c.report.Redirects = append(c.report.Redirects, Redirect{From: from, To: target, Status: rec.Code})
+	}
+	c.enqueue(target, from)
+}
+
+func (c *crawler) save(u string, rec *httptest.ResponseRecorder) error {
+	body := rec.Body.Bytes()
+	contentType := rec.Header().Get("Content-Type")
+	isHTML := strings.HasPrefix(contentType, "text/html")
+
+	// A Markdown page is reachable as both .md and .html
+	name := outputName(u, isHTML)
+	if c.written[name] {
+		return nil
+	}
+	c.written[name] = true
+
+	if err := c.out.Write(name, body); err != nil {
+		return err
+	}
+	c.report.Files = append(c.report.Files, name)
+
+	var refs []links.Ref
+	switch {
+	case isHTML:
+		refs = links.FromHTML(bytes.NewReader(body))
+	case strings.HasPrefix(contentType, "text/css"):
+		refs = links.FromCSS(string(body))
+	}
+
+	for _, ref := range refs {
+		if target, ok := links.Internal(u, ref.URL); ok {
+			c.enqueue(target, u)
+		}
+	}
+	return nil
+}
+
+// outputName maps a URL path to the file a static host would serve for it
+func outputName(u string, isHTML bool) string {
+	name := strings.TrimPrefix(u, "/")
+	ext := path.Ext(name)
+
+	switch {
+	case name == "" || strings.HasSuffix(name, "/"):
+		return name + "index.html"
+	case isHTML && strings.EqualFold(ext, ".md"):
+		// Rendered Markdown; the renderer already links to .html
+		return strings.TrimSuffix(name, ext) + ".html"
+	case isHTML && ext == "":
+		return name + ".html"
+	}
+	return name
+}
+
+// seedURLs lists the URL of every servable file in the site folder
+func seedURLs(root string) ([]string, error) {
+	urls := []string{"/"}
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		name := d.Name()
+
+		if skipName(name) || (d.IsDir() && rel == "logs") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		// index files are exported through their directory URL
+		if name == "index.html" || name == "index.htm" {
+			if dir := path.Dir(rel); dir != "." {
+				urls = append(urls, "/"+dir+"/")
+			}
+			return nil
+		}
+		urls = append(urls, "/"+rel)
+		return nil
+	})
+
+	return urls, err
+}
+
+// skipName reports files that configure a site rather than being content
+func skipName(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || siteconfig.IsFileName(name)
+}
+
+// TargetNotEmpty reports whether target is a folder with files in it, or an
+// existing .zip, which an export would write over
+func TargetNotEmpty(target string) bool {
+	if strings.EqualFold(filepath.Ext(target), ".zip") {
+		// An empty file is what a save dialog creates before exporting
+		info, err := os.Stat(target)
+		return err == nil && info.Size() > 0
+	}
+	entries, err := os.ReadDir(target)
+	return err == nil && len(entries) > 0
+}
+
+func checkTarget(root, target string) error {
+	if target == "" {
+		return fmt.Errorf("no export target given")
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(absRoot, absTarget)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("export target %s is inside the site folder", target)
+	}
+	return nil
+}
+
+func redirectsManifest(redirects []Redirect) []byte {
+	var b bytes.Buffer
+	for _, r := range redirects {
+		fmt.Fprintf(&b, "%s %s %d\n", r.From, r.To, r.Status)
+	}
+	return b.Bytes()
+}
+
+type writer interface {
+	Write(name string, data []byte) error
+	Close() error
+}
+
+type dirWriter struct {
+	dir string
+}
+
+func newDirWriter(dir string) (*dirWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &dirWriter{dir: dir}, nil
+}
+
+func (w *dirWriter) Write(name string, data []byte) error {
+	p := filepath.Join(w.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0644)
+}
+
+func (w *dirWriter) Close() error {
+	return nil
+}
+
+type zipWriter struct {
+	file *os.File
+	zw   *zip.Writer
+}
+
+func newZipWriter(target string) (*zipWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return nil, err
+	}
+	return &zipWriter{file: f, zw: zip.NewWriter(f)}, nil
+}
+
+func (w *zipWriter) Write(name string, data []byte) error {
+	method := zip.Deflate
+	// Already compressed formats gain nothing from deflate
+	if t := mime.TypeByExtension(path.Ext(name)); strings.HasPrefix(t, "image/") && !strings.Contains(t, "svg") {
+		method = zip.Store
+	}
+
+	f, err := w.zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	if err := w.zw.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,218 @@
+package har
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Bodies larger than this are recorded truncated
+const maxBodySize = 10 << 20
+
+// Recorder captures request/response pairs passing through its middleware
+type Recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Read the start of the body so it can be recorded; downstream still
+		// gets all of it
+		var reqBody []byte
+		if r.Body != nil {
+			reqBody = peekBody(r, maxBodySize)
+		}
+
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+
+		elapsed := float64(time.Since(start).Microseconds()) / 1000
+		entry := Entry{
+			StartedDateTime: start,
+			Time:            elapsed,
+			Request:         buildRequest(r, reqBody),
+			Response:        buildResponse(cw),
+			Timings:         Timings{Wait: elapsed},
+		}
+
+		rec.mu.Lock()
+		rec.entries = append(rec.entries, entry)
+		rec.mu.Unlock()
+	})
+}
+
+// peekBody reads up to limit bytes of r's body and puts them back in front
+// of the rest
+func peekBody(r *http.Request, limit int64) []byte {
+	body, _ := io.ReadAll(io.LimitReader(r.Body, limit))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	return body
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (rec *Recorder) Len() int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return len(rec.entries)
+}
+
+func (rec *Recorder) Reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.entries = nil
+}
+
+// HAR returns a snapshot of everything recorded so far
+func (rec *Recorder) HAR() *HAR {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	h := New()
+	h.Log.Entries = append(h.Log.Entries, rec.entries...)
+	return h
+}
+
+func buildRequest(r *http.Request, body []byte) Request {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	req := Request{
+		Method:      r.Method,
+		URL:         fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.RequestURI()),
+		HTTPVersion: r.Proto,
+		Cookies:     []Cookie{},
+		Headers:     headerPairs(r.Header),
+		QueryString: []NameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+
+	for _, c := range r.Cookies() {
+		req.Cookies = append(req.Cookies, Cookie{Name: c.Name, Value: c.Value})
+	}
+	for name, values := range r.URL.Query() {
+		for _, v := range values {
+			req.QueryString = append(req.QueryString, NameValue{Name: name, Value: v})
+		}
+	}
+	if len(body) > 0 {
+		req.PostData = &PostData{
+			MimeType: r.Header.Get("Content-Type"),
+			Text:     string(body),
+		}
+		if !utf8.Valid(body) {
+			req.PostData.Text = base64.StdEncoding.EncodeToString(body)
+			req.PostData.Encoding = "base64"
+		}
+	}
+
+	return req
+}
+
+func buildResponse(cw *captureWriter) Response {
+	header := cw.Header()
+	body := cw.body.Bytes()
+	mimeType := header.Get("Content-Type")
+
+	content := Content{
+		Size:     cw.size,
+		MimeType: mimeType,
+	}
+	if isText(mimeType) && utf8.Valid(body) {
+		content.Text = string(body)
+	} else if len(body) > 0 {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+
+	return Response{
+		Status:      cw.status,
+		StatusText:  http.StatusText(cw.status),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []Cookie{},
+		Headers:     headerPairs(header),
+		Content:     content,
+		RedirectURL: header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    cw.size,
+	}
+}
+
+func headerPairs(h http.Header) []NameValue {
+	pairs := []NameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			pairs = append(pairs, NameValue{Name: name, Value: v})
+		}
+	}
+	return pairs
+}
+
+func isText(mimeType string) bool {
+	mimeType = strings.ToLower(mimeType)
+	return strings.HasPrefix(mimeType, "text/") ||
+		strings.Contains(mimeType, "json") ||
+		strings.Contains(mimeType, "javascript") ||
+		strings.Contains(mimeType, "xml") ||
+		strings.Contains(mimeType, "svg")
+}
+
+// captureWriter tees the response body into a buffer
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+	body   bytes.Buffer
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	cw.status = code
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(b)
+	cw.size += n
+	if room := maxBodySize - cw.body.Len(); room > 0 {
+		if n > room {
+			cw.body.Write(b[:room])
+		} else {
+			cw.body.Write(b[:n])
+		}
+	}
+	return n, err
+}
+
+func (cw *captureWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, fmt.Errorf("hijacking not supported")
+}
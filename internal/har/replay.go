@@ -0,0 +1,162 @@
+package har
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Headers that describe the original transfer rather than the content
+var skipHeaders = map[string]bool{
+	"content-length":    true,
+	"content-encoding":  true,
+	"transfer-encoding": true,
+	"connection":        true,
+	"date":              true,
+}
+
+// Replayer serves recorded responses back for matching requests.
+// Requests are keyed by method and URL (host ignored), and optionally the
+// request body. When a key was recorded several times, the responses are
+// served in recorded order and the last one is repeated once exhausted.
+type Replayer struct {
+	MatchBody bool
+
+	mu      sync.Mutex
+	entries map[string][]Entry
+	hits    map[string]int
+}
+
+func NewReplayer(h *HAR, matchBody bool) *Replayer {
+	rp := &Replayer{
+		MatchBody: matchBody,
+		entries:   make(map[string][]Entry),
+		hits:      make(map[string]int),
+	}
+
+	for _, e := range h.Log.Entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			continue
+		}
+		var body []byte
+		if post := e.Request.PostData; post != nil {
+			body = []byte(post.Text)
+			if post.Encoding == "base64" {
+				if decoded, err := base64.StdEncoding.DecodeString(post.Text); err == nil {
+					body = decoded
+				}
+			}
+		}
+		key := rp.key(e.Request.Method, u, body)
+		rp.entries[key] = append(rp.entries[key], e)
+	}
+
+	return rp
+}
+
+func LoadReplayer(path string, matchBody bool) (*Replayer, error) {
+	h, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(h, matchBody), nil
+}
+
+func (rp *Replayer) Len() int {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	n := 0
+	for _, list := range rp.entries {
+		n += len(list)
+	}
+	return n
+}
+
+// Lookup returns the next recorded entry for r, or nil when nothing matches
+func (rp *Replayer) Lookup(r *http.Request) *Entry {
+	var body []byte
+	if rp.MatchBody && r.Body != nil {
+		body = peekBody(r, maxBodySize)
+	}
+
+	key := rp.key(r.Method, r.URL, body)
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	list := rp.entries[key]
+	if len(list) == 0 {
+		return nil
+	}
+
+	i := rp.hits[key]
+	if i >= len(list) {
+		i = len(list) - 1
+	}
+	rp.hits[key]++
+
+	return &list[i]
+}
+
+// Middleware serves recorded responses and passes unmatched requests to next
+func (rp *Replayer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := rp.Lookup(r)
+		if entry == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeEntry(w, entry)
+	})
+}
+
+func (rp *Replayer) key(method string, u *url.URL, body []byte) string {
+	key := strings.ToUpper(method) + " " + u.EscapedPath()
+	if q := u.Query(); len(q) > 0 {
+		// Encode sorts by key, so parameter order doesn't matter
+		key += "?" + q.Encode()
+	}
+	if rp.MatchBody && len(body) > 0 {
+		sum := sha256.Sum256(body)
+		key += " " + hex.EncodeToString(sum[:])
+	}
+	return key
+}
+
+func writeEntry(w http.ResponseWriter, entry *Entry) {
+	resp := entry.Response
+
+	body := []byte(resp.Content.Text)
+	if resp.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(resp.Content.Text)
+		if err == nil {
+			body = decoded
+		}
+	}
+
+	for _, h := range resp.Headers {
+		if skipHeaders[strings.ToLower(h.Name)] {
+			continue
+		}
+		w.Header().Add(h.Name, h.Value)
+	}
+	if resp.Content.MimeType != "" && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", resp.Content.MimeType)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("X-Shinobi-Replay", "1")
+
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
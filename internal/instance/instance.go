@@ -0,0 +1,183 @@
+package instance
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"shinobi-webserver/internal/config"
+	"shinobi-webserver/internal/filelock"
+)
+
+// Actions a second launch can hand to the running instance
+const (
+	ActionShow  = "show"
+	ActionOpen  = "open"
+	ActionStart = "start"
+	ActionStop  = "stop"
+)
+
+// ErrRunning is returned by Acquire when another instance uses the same
+// config file
+var ErrRunning = errors.New("another instance is running")
+
+// Request asks the running instance to do something
+type Request struct {
+	Token  string   `json:"token"`
+	Action string   `json:"action"`
+	Args   []string `json:"args,omitempty"`
+}
+
+type response struct {
+	Error string `json:"error,omitempty"`
+}
+
+// Handler carries out a request in the running instance
+type Handler func(action string, args []string) error
+
+// Instance is held by the one GUI process of a config file
+type Instance struct {
+	lock     *filelock.Lock
+	listener net.Listener
+	token    string
+}
+
+// address is where the running instance can be reached; only the user can
+// read it
+type address struct {
+	Addr  string `json:"addr"`
+	Token string `json:"token"`
+}
+
+func lockPath() string {
+	return filepath.Join(config.Dir(), "instance.lock")
+}
+
+func addressPath() string {
+	return filepath.Join(config.Dir(), "instance.json")
+}
+
+// Acquire makes this process the instance for the config file in use
+func Acquire() (*Instance, error) {
+	lock, err := filelock.TryAcquire(lockPath())
+	if errors.Is(err, filelock.ErrLocked) {
+		return nil, ErrRunning
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Instance{lock: lock}, nil
+}
+
+// Listen accepts requests from later launches on a loopback socket and
+// passes them to handle, one at a time
+func (i *Instance) Listen(handle Handler) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		listener.Close()
+		return err
+	}
+	i.token = hex.EncodeToString(token)
+
+	data, _ := json.Marshal(address{Addr: listener.Addr().String(), Token: i.token})
+	if err := os.WriteFile(addressPath(), data, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+	i.listener = listener
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			i.serve(conn, handle)
+		}
+	}()
+	return nil
+}
+
+func (i *Instance) serve(conn net.Conn, handle Handler) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var req Request
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		return
+	}
+
+	var resp response
+	if req.Token != i.token {
+		resp.Error = "not allowed"
+	} else if err := handle(req.Action, req.Args); err != nil {
+		resp.Error = err.Error()
+	}
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// Close stops listening and gives up the lock
+func (i *Instance) Close() {
+	if i.listener != nil {
+		i.listener.Close()
+		os.Remove(addressPath())
+	}
+	i.lock.Release()
+}
+
+// Send hands a request to the running instance and returns its error, if
+// any. It waits briefly for an instance that is still starting up.
+func Send(action string, args ...string) error {
+	var (
+		addr address
+		err  error
+	)
+	for attempt := 0; attempt < 20; attempt++ {
+		if attempt > 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		var data []byte
+		if data, err = os.ReadFile(addressPath()); err != nil {
+			continue
+		}
+		if err = json.Unmarshal(data, &addr); err != nil {
+			continue
+		}
+
+		var conn net.Conn
+		if conn, err = net.DialTimeout("tcp", addr.Addr, time.Second); err != nil {
+			continue
+		}
+		return exchange(conn, Request{Token: addr.Token, Action: action, Args: args})
+	}
+	return fmt.Errorf("the running instance can't be reached: %v", err)
+}
+
+func exchange(conn net.Conn, req Request) error {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package launch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"shinobi-webserver/internal/config"
+)
+
+// Failure is a site that was due to start on launch but didn't
+type Failure struct {
+	Site config.Site
+	Err  error
+}
+
+// Summary records what a launch did
+type Summary struct {
+	Started []config.Site
+	Failed  []Failure
+}
+
+// Message describes the failures in a few lines, for a notification
+func (s *Summary) Message() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Started %d of %d sites", len(s.Started), len(s.Started)+len(s.Failed))
+	for _, f := range s.Failed {
+		fmt.Fprintf(&b, "\n%s: %v", f.Site.Name, f.Err)
+	}
+	return b.String()
+}
+
+// Plan returns the sites to start on launch, in every workspace: those set
+// to auto-start, those running at the last exit, and the sites they depend
+// on. Dependencies come before the sites needing them; otherwise lower
+// StartOrder goes first. Sites that can't be ordered are returned as
+// failures.
+func Plan(cfg *config.Config) ([]config.Site, []Failure) {
+	byID := make(map[string]config.Site)
+	var all []config.Site
+	for _, ws := range cfg.AllWorkspaces() {
+		for _, site := range ws.Sites {
+			byID[site.ID] = site
+			all = append(all, site)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].StartOrder < all[j].StartOrder
+	})
+
+	var (
+		planned  []config.Site
+		failures []Failure
+		// 1 while visiting, 2 once planned or failed
+		state  = make(map[string]int)
+		failed = make(map[string]error)
+	)
+	var visit func(site config.Site) error
+	visit = func(site config.Site) error {
+		if state[site.ID] == 2 {
+			return failed[site.ID]
+		}
+		state[site.ID] = 1
+
+		var err error
+		for _, id := range site.DependsOn {
+			dep, ok := byID[id]
+			if !ok {
+				err = fmt.Errorf("depends on site %s, which doesn't exist", id)
+				break
+			}
+			if state[id] == 1 {
+				err = fmt.Errorf("dependency cycle with '%s'", dep.Name)
+				break
+			}
+			if visit(dep) != nil {
+				err = fmt.Errorf("depends on '%s', which can't start", dep.Name)
+				break
+			}
+		}
+
+		state[site.ID] = 2
+		if err != nil {
+			failed[site.ID] = err
+			failures = append(failures, Failure{Site: site, Err: err})
+			return err
+		}
+		planned = append(planned, site)
+		return nil
+	}
+
+	for _, site := range all {
+		if site.AutoStart || site.Running {
+			visit(site)
+		}
+	}
+	return planned, failures
+}
+
+// Run starts the planned sites one by one. A site is skipped when its port
+// is taken or a site it depends on failed to start.
+func Run(cfg *config.Config, start func(site config.Site) error) *Summary {
+	planned, failures := Plan(cfg)
+	summary := &Summary{Failed: failures}
+
+	failed := make(map[string]string)
+	for _, f := range failures {
+		failed[f.Site.ID] = f.Site.Name
+	}
+
+	for _, site := range planned {
+		err := dependencyFailed(site, failed)
+		if err == nil && !config.PortFree(site.Port) {
+			err = fmt.Errorf("port %d is taken by another program", site.Port)
+		}
+		if err == nil {
+			err = start(site)
+		}
+		if err != nil {
+			failed[site.ID] = site.Name
+			summary.Failed = append(summary.Failed, Failure{Site: site, Err: err})
+			continue
+		}
+		summary.Started = append(summary.Started, site)
+	}
+	return summary
+}
+
+func dependencyFailed(site config.Site, failed map[string]string) error {
+	for _, id := range site.DependsOn {
+		if name, ok := failed[id]; ok {
+			return fmt.Errorf("'%s' didn't start", name)
+		}
+	}
+	return nil
+}
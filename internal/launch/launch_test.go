@@ -0,0 +1,154 @@
+package launch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"shinobi-webserver/internal/config"
+)
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name   string
+		sites  []config.Site
+		want   []string
+		failed map[string]string
+	}{
+		{
+			name: "nothing to start",
+			sites: []config.Site{
+				{ID: "a"},
+				{ID: "b"},
+			},
+		},
+		{
+			name: "auto-start and running",
+			sites: []config.Site{
+				{ID: "a", AutoStart: true},
+				{ID: "b"},
+				{ID: "c", Running: true},
+			},
+			want: []string{"a", "c"},
+		},
+		{
+			name: "start order",
+			sites: []config.Site{
+				{ID: "a", AutoStart: true, StartOrder: 3},
+				{ID: "b", AutoStart: true, StartOrder: 1},
+				{ID: "c", AutoStart: true, StartOrder: 2},
+				{ID: "d", AutoStart: true, StartOrder: 1},
+			},
+			want: []string{"b", "d", "c", "a"},
+		},
+		{
+			name: "dependencies first, even if not set to start",
+			sites: []config.Site{
+				{ID: "app", AutoStart: true, DependsOn: []string{"api"}},
+				{ID: "api", DependsOn: []string{"db"}, StartOrder: 5},
+				{ID: "db"},
+			},
+			want: []string{"db", "api", "app"},
+		},
+		{
+			name: "dependencies beat start order",
+			sites: []config.Site{
+				{ID: "a", AutoStart: true, StartOrder: 1, DependsOn: []string{"b"}},
+				{ID: "b", AutoStart: true, StartOrder: 2},
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name: "shared dependency planned once",
+			sites: []config.Site{
+				{ID: "a", AutoStart: true, DependsOn: []string{"c"}},
+				{ID: "b", AutoStart: true, DependsOn: []string{"c"}},
+				{ID: "c"},
+			},
+			want: []string{"c", "a", "b"},
+		},
+		{
+			name: "missing dependency",
+			sites: []config.Site{
+				{ID: "a", AutoStart: true, DependsOn: []string{"gone"}},
+				{ID: "b", AutoStart: true},
+			},
+			want:   []string{"b"},
+			failed: map[string]string{"a": "doesn't exist"},
+		},
+		{
+			name: "cycle",
+			sites: []config.Site{
+				{ID: "a", Name: "A", AutoStart: true, DependsOn: []string{"b"}},
+				{ID: "b", Name: "B", DependsOn: []string{"a"}},
+				{ID: "c", AutoStart: true},
+			},
+			want: []string{"c"},
+			failed: map[string]string{
+				"b": "dependency cycle with 'A'",
+				"a": "depends on 'B', which can't start",
+			},
+		},
+		{
+			name: "depends on itself",
+			sites: []config.Site{
+				{ID: "a", Name: "A", Running: true, DependsOn: []string{"a"}},
+			},
+			failed: map[string]string{"a": "dependency cycle with 'A'"},
+		},
+		{
+			name: "failure spreads to dependents",
+			sites: []config.Site{
+				{ID: "a", Name: "A", AutoStart: true, DependsOn: []string{"gone"}},
+				{ID: "b", AutoStart: true, DependsOn: []string{"a"}},
+			},
+			failed: map[string]string{
+				"a": "doesn't exist",
+				"b": "depends on 'A', which can't start",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewDefault()
+			cfg.Workspaces[0].Sites = tt.sites
+
+			planned, failures := Plan(cfg)
+
+			var got []string
+			for _, site := range planned {
+				got = append(got, site.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planned %v, want %v", got, tt.want)
+			}
+
+			if len(failures) != len(tt.failed) {
+				t.Errorf("got %d failures %v, want %d", len(failures), failures, len(tt.failed))
+			}
+			for _, f := range failures {
+				want, ok := tt.failed[f.Site.ID]
+				if !ok {
+					t.Errorf("unexpected failure of %s: %v", f.Site.ID, f.Err)
+				} else if !strings.Contains(f.Err.Error(), want) {
+					t.Errorf("failure of %s = %q, want it to contain %q", f.Site.ID, f.Err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanAcrossWorkspaces(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.Workspaces[0].Sites = []config.Site{{ID: "a", AutoStart: true, DependsOn: []string{"b"}}}
+	cfg.Workspaces = append(cfg.Workspaces, config.Workspace{
+		Name:  "Other",
+		Sites: []config.Site{{ID: "b"}},
+	})
+
+	planned, failures := Plan(cfg)
+	if len(failures) != 0 || len(planned) != 2 || planned[0].ID != "b" || planned[1].ID != "a" {
+		t.Errorf("Plan = %v, %v; want b then a", planned, failures)
+	}
+}
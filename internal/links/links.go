@@ -0,0 +1,154 @@
+package links
+
+import (
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Ref is a reference from a page or stylesheet to another URL
+type Ref struct {
+	URL  string
+	Tag  string // element name, or "css" for url() references
+	Attr string
+}
+
+var cssURL = regexp.MustCompile(`url\(\s*['"]?([^'")]+)['"]?\s*\)`)
+
+// attributes holding a single URL, by element
+var urlAttrs = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"script": {"src"},
+	"img":    {"src"},
+	"iframe": {"src"},
+	"frame":  {"src"},
+	"embed":  {"src"},
+	"source": {"src"},
+	"track":  {"src"},
+	"audio":  {"src"},
+	"video":  {"src", "poster"},
+	"input":  {"src"},
+	"object": {"data"},
+}
+
+// FromHTML extracts href, src, srcset, inline style and <style> references
+func FromHTML(r io.Reader) []Ref {
+	var refs []Ref
+	z := html.NewTokenizer(r)
+	inStyle := false
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return refs
+
+		case html.TextToken:
+			if inStyle {
+				refs = append(refs, FromCSS(string(z.Text()))...)
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if string(name) == "style" {
+				inStyle = false
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			if tag == "style" {
+				inStyle = true
+			}
+
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attr := string(key)
+				value := strings.TrimSpace(string(val))
+				if value == "" {
+					continue
+				}
+
+				switch {
+				case attr == "srcset":
+					for _, u := range parseSrcset(value) {
+						refs = append(refs, Ref{URL: u, Tag: tag, Attr: attr})
+					}
+				case attr == "style":
+					for _, ref := range FromCSS(value) {
+						ref.Tag = tag
+						ref.Attr = attr
+						refs = append(refs, ref)
+					}
+				case contains(urlAttrs[tag], attr):
+					refs = append(refs, Ref{URL: value, Tag: tag, Attr: attr})
+				}
+			}
+		}
+	}
+}
+
+// FromCSS extracts url() references from a stylesheet
+func FromCSS(css string) []Ref {
+	var refs []Ref
+	for _, m := range cssURL.FindAllStringSubmatch(css, -1) {
+		u := strings.TrimSpace(m[1])
+		if u != "" {
+			refs = append(refs, Ref{URL: u, Tag: "css", Attr: "url"})
+		}
+	}
+	return refs
+}
+
+func parseSrcset(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// Internal resolves ref against the page URL path and returns the target
+// path when it points into the same site, without query or fragment
+func Internal(pagePath, ref string) (string, bool) {
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" {
+		return "", false
+	}
+	if u.Path == "" {
+		// Fragment or query on the page itself
+		return "", false
+	}
+
+	target := u.Path
+	if !strings.HasPrefix(target, "/") {
+		target = path.Join(path.Dir(pagePath+"x"), target)
+		if strings.HasSuffix(u.Path, "/") && !strings.HasSuffix(target, "/") {
+			target += "/"
+		}
+	}
+	return target, true
+}
+
+// IsPageLink reports whether a reference is navigation rather than a resource
+func (r Ref) IsPageLink() bool {
+	return (r.Tag == "a" || r.Tag == "area") && r.Attr == "href"
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// A deliberately small highlighter: it knows comments, strings, numbers and
+// keywords, which covers what documentation snippets need without pulling
+// a lexer library into the build.
+type language struct {
+	keywords     map[string]bool
+	lineComments []string
+	blockComment [2]string
+	quotes       string
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var cStyle = [2]string{"/*", "*/"}
+
+var languages = map[string]*language{
+	"go": {
+		keywords:     words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota"),
+		lineComments: []string{"//"},
+		blockComment: cStyle,
+		quotes:       "\"'`",
+	},
+	"js": {
+		keywords:     words("async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof let new of return static super switch this throw try typeof var void while with yield null undefined true false"),
+		lineComments: []string{"//"},
+		blockComment: cStyle,
+		quotes:       "\"'`",
+	},
+	"python": {
+		keywords:     words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"shell": {
+		keywords:     words("if then else elif fi for while until do done case esac function in return export local echo cd exit set unset"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"css": {
+		keywords:     words("important inherit initial unset none auto block inline flex grid absolute relative fixed"),
+		blockComment: cStyle,
+		quotes:       "\"'",
+	},
+	"html": {
+		keywords:     words("html head body div span a p script style link meta title img ul ol li table tr td th section header footer nav main"),
+		blockComment: [2]string{"<!--", "-->"},
+		quotes:       "\"'",
+	},
+	"json": {
+		keywords: words("true false null"),
+		quotes:   "\"",
+	},
+	"yaml": {
+		keywords:     words("true false null yes no on off"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"sql": {
+		keywords:     words("select from where insert into values update set delete create table drop alter and or not null join left right inner outer on group by order having limit as distinct SELECT FROM WHERE INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER AND OR NOT NULL JOIN LEFT RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT AS DISTINCT"),
+		lineComments: []string{"--"},
+		blockComment: cStyle,
+		quotes:       "'\"",
+	},
+}
+
+var aliases = map[string]string{
+	"golang":     "go",
+	"javascript": "js",
+	"ts":         "js",
+	"typescript": "js",
+	"jsx":        "js",
+	"tsx":        "js",
+	"py":         "python",
+	"sh":         "shell",
+	"bash":       "shell",
+	"zsh":        "shell",
+	"console":    "shell",
+	"scss":       "css",
+	"xml":        "html",
+	"svg":        "html",
+	"yml":        "yaml",
+}
+
+func lookupLanguage(name string) *language {
+	name = strings.ToLower(name)
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	return languages[name]
+}
+
+// highlight returns HTML-escaped code with tokens wrapped in hl-* spans
+func highlight(code, lang string) string {
+	l := lookupLanguage(lang)
+	if l == nil {
+		return html.EscapeString(code)
+	}
+
+	var b strings.Builder
+	span := func(class, text string) {
+		b.WriteString(`<span class="hl-` + class + `">`)
+		b.WriteString(html.EscapeString(text))
+		b.WriteString("</span>")
+	}
+
+	i := 0
+	for i < len(code) {
+		rest := code[i:]
+
+		if l.blockComment[0] != "" && strings.HasPrefix(rest, l.blockComment[0]) {
+			end := strings.Index(rest[len(l.blockComment[0]):], l.blockComment[1])
+			n := len(rest)
+			if end >= 0 {
+				n = len(l.blockComment[0]) + end + len(l.blockComment[1])
+			}
+			span("comment", rest[:n])
+			i += n
+			continue
+		}
+
+		if prefix := matchPrefix(rest, l.lineComments); prefix != "" {
+			n := strings.IndexByte(rest, '\n')
+			if n < 0 {
+				n = len(rest)
+			}
+			span("comment", rest[:n])
+			i += n
+			continue
+		}
+
+		c := rest[0]
+		if strings.IndexByte(l.quotes, c) >= 0 {
+			n := scanString(rest)
+			span("string", rest[:n])
+			i += n
+			continue
+		}
+
+		if c >= '0' && c <= '9' {
+			n := 1
+			for n < len(rest) && (isWordByte(rest[n]) || rest[n] == '.') {
+				n++
+			}
+			span("number", rest[:n])
+			i += n
+			continue
+		}
+
+		if isWordByte(c) {
+			n := 1
+			for n < len(rest) && isWordByte(rest[n]) {
+				n++
+			}
+			if l.keywords[rest[:n]] {
+				span("keyword", rest[:n])
+			} else {
+				b.WriteString(html.EscapeString(rest[:n]))
+			}
+			i += n
+			continue
+		}
+
+		b.WriteString(html.EscapeString(rest[:1]))
+		i++
+	}
+
+	return b.String()
+}
+
+func matchPrefix(s string, prefixes []string) string {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// scanString returns the length of the quoted string at the start of s
+func scanString(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			if quote != '`' {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
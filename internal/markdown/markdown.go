@@ -0,0 +1,389 @@
+package markdown
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+//go:embed layout.html
+var defaultLayout string
+
+// Page is the data passed to the layout template
+type Page struct {
+	Title   string
+	Path    string
+	RawURL  string
+	Content template.HTML
+	TOC     []Heading
+	Nav     []*NavItem
+}
+
+type Heading struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+type NavItem struct {
+	Title    string
+	URL      string
+	Active   bool
+	Children []*NavItem
+}
+
+// Renderer serves the Markdown files of a site folder as HTML pages
+type Renderer struct {
+	Root string
+	// Layout is a html/template file, relative to Root unless absolute.
+	// Empty uses the built-in layout.
+	Layout   string
+	LogError func(string)
+
+	md goldmark.Markdown
+}
+
+func New(root, layout string) *Renderer {
+	return &Renderer{
+		Root:   root,
+		Layout: layout,
+		md: goldmark.New(
+			goldmark.WithExtensions(extension.GFM),
+			goldmark.WithParserOptions(
+				parser.WithAutoHeadingID(),
+				parser.WithASTTransformers(util.Prioritized(linkRewriter{}, 100)),
+			),
+			goldmark.WithRendererOptions(
+				renderer.WithNodeRenderers(util.Prioritized(codeRenderer{}, 100)),
+				html.WithUnsafe(),
+			),
+		),
+	}
+}
+
+// Middleware renders requests that resolve to a Markdown file and passes
+// everything else on. "?raw=1" returns the Markdown source.
+func (m *Renderer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		file := m.Resolve(r.URL.Path)
+		if file == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.URL.Query().Get("raw") == "1" {
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			http.ServeFile(w, r, filepath.Join(m.Root, filepath.FromSlash(file)))
+			return
+		}
+
+		page, err := m.Render(file)
+		if err != nil {
+			m.logError(fmt.Sprintf("Markdown error in %s: %v", file, err))
+			http.Error(w, "failed to render "+file, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+}
+
+// Resolve maps a URL path to the Markdown file (slash separated, relative
+// to Root) that should be rendered for it, or "" when none applies.
+func (m *Renderer) Resolve(urlPath string) string {
+	p := path.Clean("/" + urlPath)
+	rel := strings.TrimPrefix(p, "/")
+
+	switch {
+	case strings.HasSuffix(p, ".md"):
+		if m.isFile(rel) {
+			return rel
+		}
+	case strings.HasSuffix(p, ".html"):
+		// Links between Markdown pages are rewritten to .html
+		if !m.isFile(rel) {
+			if md := strings.TrimSuffix(rel, ".html") + ".md"; m.isFile(md) {
+				return md
+			}
+		}
+	case strings.HasSuffix(urlPath, "/") || p == "/":
+		// Directory without an HTML index
+		if m.isFile(path.Join(rel, "index.html")) || m.isFile(path.Join(rel, "index.htm")) {
+			return ""
+		}
+		for _, name := range []string{"index.md", "README.md", "readme.md"} {
+			if md := path.Join(rel, name); m.isFile(md) {
+				return md
+			}
+		}
+	case path.Ext(p) == "":
+		if !m.exists(rel) && m.isFile(rel+".md") {
+			return rel + ".md"
+		}
+	}
+	return ""
+}
+
+// Render produces the complete HTML page for a Markdown file
+func (m *Renderer) Render(file string) ([]byte, error) {
+	source, err := os.ReadFile(filepath.Join(m.Root, filepath.FromSlash(file)))
+	if err != nil {
+		return nil, err
+	}
+
+	doc := m.md.Parser().Parse(text.NewReader(source))
+
+	var body bytes.Buffer
+	if err := m.md.Renderer().Render(&body, source, doc); err != nil {
+		return nil, err
+	}
+
+	toc, title := headings(doc, source)
+	if title == "" {
+		title = titleFromName(path.Base(file))
+	}
+
+	page := Page{
+		Title:   title,
+		Path:    "/" + file,
+		RawURL:  "/" + file + "?raw=1",
+		Content: template.HTML(body.String()),
+		TOC:     toc,
+		Nav:     m.nav("", "/"+file),
+	}
+
+	tmpl, err := m.layout()
+	if err != nil {
+		// A broken custom layout shouldn't take the docs down
+		m.logError(fmt.Sprintf("Markdown layout error, using default: %v", err))
+		tmpl = template.Must(template.New("layout").Parse(defaultLayout))
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, page); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (m *Renderer) layout() (*template.Template, error) {
+	if m.Layout == "" {
+		return template.New("layout").Parse(defaultLayout)
+	}
+
+	// Parsed on every request so edits show up without a restart
+	layoutPath := m.Layout
+	if !filepath.IsAbs(layoutPath) {
+		layoutPath = filepath.Join(m.Root, layoutPath)
+	}
+	data, err := os.ReadFile(layoutPath)
+	if err != nil {
+		return nil, err
+	}
+	return template.New("layout").Parse(string(data))
+}
+
+// nav builds the sidebar tree from the Markdown files under dir
+func (m *Renderer) nav(dir, current string) []*NavItem {
+	entries, err := os.ReadDir(filepath.Join(m.Root, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil
+	}
+
+	var files, dirs []*NavItem
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "logs" {
+			continue
+		}
+		rel := path.Join(dir, name)
+
+		if e.IsDir() {
+			children := m.nav(rel, current)
+			if len(children) == 0 {
+				continue
+			}
+			item := &NavItem{Title: titleFromName(name), Children: children}
+			for _, child := range children {
+				if child.Active || isChildActive(child) {
+					item.Active = true
+				}
+			}
+			dirs = append(dirs, item)
+			continue
+		}
+
+		if !strings.EqualFold(path.Ext(name), ".md") {
+			continue
+		}
+		url := "/" + strings.TrimSuffix(rel, path.Ext(rel)) + ".html"
+		files = append(files, &NavItem{
+			Title:  titleFromName(name),
+			URL:    url,
+			Active: "/"+rel == current,
+		})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return navRank(files[i]) < navRank(files[j])
+	})
+	return append(files, dirs...)
+}
+
+func isChildActive(item *NavItem) bool {
+	for _, child := range item.Children {
+		if child.Active || isChildActive(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// navRank puts index/README pages first, otherwise keeps directory order
+func navRank(item *NavItem) int {
+	switch strings.ToLower(path.Base(item.URL)) {
+	case "index.html", "readme.html":
+		return 0
+	}
+	return 1
+}
+
+func (m *Renderer) isFile(rel string) bool {
+	info, err := os.Stat(filepath.Join(m.Root, filepath.FromSlash(rel)))
+	return err == nil && !info.IsDir()
+}
+
+func (m *Renderer) exists(rel string) bool {
+	_, err := os.Stat(filepath.Join(m.Root, filepath.FromSlash(rel)))
+	return err == nil
+}
+
+func (m *Renderer) logError(msg string) {
+	if m.LogError != nil {
+		m.LogError(msg)
+	}
+}
+
+// headings collects the table of contents and the first h1 as title
+func headings(doc ast.Node, source []byte) ([]Heading, string) {
+	var toc []Heading
+	title := ""
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		text := string(h.Text(source))
+		if h.Level == 1 && title == "" {
+			title = text
+		}
+		if h.Level >= 2 && h.Level <= 4 {
+			id := ""
+			if v, ok := h.AttributeString("id"); ok {
+				if b, ok := v.([]byte); ok {
+					id = string(b)
+				}
+			}
+			toc = append(toc, Heading{Level: h.Level, ID: id, Text: text})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+
+	return toc, title
+}
+
+func titleFromName(name string) string {
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if strings.EqualFold(name, "index") || strings.EqualFold(name, "readme") {
+		return "Overview"
+	}
+	name = strings.NewReplacer("-", " ", "_", " ").Replace(name)
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// linkRewriter points relative links to other Markdown files at their
+// rendered .html URL, so pages keep working after a static export
+type linkRewriter struct{}
+
+func (linkRewriter) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		link, ok := n.(*ast.Link)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		dest := string(link.Destination)
+		if strings.Contains(dest, "://") || strings.HasPrefix(dest, "mailto:") {
+			return ast.WalkContinue, nil
+		}
+
+		target, suffix := dest, ""
+		if i := strings.IndexAny(dest, "?#"); i >= 0 {
+			target, suffix = dest[:i], dest[i:]
+		}
+		if strings.HasSuffix(strings.ToLower(target), ".md") {
+			link.Destination = []byte(target[:len(target)-3] + ".html" + suffix)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// codeRenderer renders fenced code blocks with syntax highlighting
+type codeRenderer struct{}
+
+func (codeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, renderFencedCode)
+}
+
+func renderFencedCode(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+	lang := string(n.Language(source))
+
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	if lang != "" {
+		fmt.Fprintf(w, `<pre class="highlight"><code class="language-%s">`, template.HTMLEscapeString(lang))
+	} else {
+		w.WriteString(`<pre class="highlight"><code>`)
+	}
+	w.WriteString(highlight(code.String(), lang))
+	w.WriteString("</code></pre>\n")
+
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,239 @@
+package netsim
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Profile describes the network conditions to simulate for a site.
+// Latency and bandwidth apply to every request; drops and injected errors
+// only to requests matching FaultPaths (all requests when empty).
+type Profile struct {
+	Name         string   `json:"name"`
+	LatencyMs    int      `json:"latencyMs,omitempty"`
+	JitterMs     int      `json:"jitterMs,omitempty"`
+	DownKbps     int      `json:"downKbps,omitempty"`
+	DropPercent  float64  `json:"dropPercent,omitempty"`
+	ErrorPercent float64  `json:"errorPercent,omitempty"`
+	ErrorStatus  int      `json:"errorStatus,omitempty"`
+	FaultPaths   []string `json:"faultPaths,omitempty"`
+}
+
+var builtin = []Profile{
+	{Name: "4G", LatencyMs: 50, JitterMs: 20, DownKbps: 9000},
+	{Name: "3G", LatencyMs: 300, JitterMs: 100, DownKbps: 750},
+	{Name: "Slow 3G", LatencyMs: 2000, JitterMs: 300, DownKbps: 400},
+	{Name: "Flaky", LatencyMs: 100, JitterMs: 400, DropPercent: 5, ErrorPercent: 10, ErrorStatus: http.StatusServiceUnavailable},
+	{Name: "Offline", DropPercent: 100},
+}
+
+// Builtin returns the predefined profiles
+func Builtin() []Profile {
+	return append([]Profile(nil), builtin...)
+}
+
+// Find looks a profile up by name, custom profiles taking precedence
+func Find(name string, custom []Profile) *Profile {
+	if name == "" {
+		return nil
+	}
+	for _, list := range [][]Profile{custom, builtin} {
+		for i := range list {
+			if strings.EqualFold(list[i].Name, name) {
+				p := list[i]
+				return &p
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Profile) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if p.LatencyMs < 0 || p.JitterMs < 0 || p.DownKbps < 0 {
+		return fmt.Errorf("profile %q: latency, jitter and bandwidth must not be negative", p.Name)
+	}
+	if p.DropPercent < 0 || p.DropPercent > 100 || p.ErrorPercent < 0 || p.ErrorPercent > 100 {
+		return fmt.Errorf("profile %q: percentages must be between 0 and 100", p.Name)
+	}
+	if p.ErrorStatus != 0 && (p.ErrorStatus < 500 || p.ErrorStatus > 599) {
+		return fmt.Errorf("profile %q: error status must be a 5xx code", p.Name)
+	}
+	for _, pattern := range p.FaultPaths {
+		if _, err := path.Match(pattern, "/"); err != nil {
+			return fmt.Errorf("profile %q: invalid fault path %q", p.Name, pattern)
+		}
+	}
+	return nil
+}
+
+func (p *Profile) matchesFault(urlPath string) bool {
+	if len(p.FaultPaths) == 0 {
+		return true
+	}
+	for _, pattern := range p.FaultPaths {
+		if ok, _ := path.Match(pattern, urlPath); ok {
+			return true
+		}
+		// Treat "/api/" style patterns as prefixes
+		if strings.HasSuffix(pattern, "/") && strings.HasPrefix(urlPath, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Simulator applies the active profile to requests. The profile can be
+// swapped at any time; requests already in flight keep the one they started with.
+type Simulator struct {
+	mu      sync.Mutex
+	profile *Profile
+	rnd     *rand.Rand
+}
+
+func New() *Simulator {
+	return &Simulator{
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetProfile activates p; nil disables simulation
+func (s *Simulator) SetProfile(p *Profile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profile = p
+}
+
+func (s *Simulator) Profile() *Profile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profile
+}
+
+func (s *Simulator) roll(percent float64) bool {
+	if percent <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Float64()*100 < percent
+}
+
+func (s *Simulator) delay(p *Profile) time.Duration {
+	d := time.Duration(p.LatencyMs) * time.Millisecond
+	if p.JitterMs > 0 {
+		s.mu.Lock()
+		d += time.Duration(s.rnd.Intn(p.JitterMs+1)) * time.Millisecond
+		s.mu.Unlock()
+	}
+	return d
+}
+
+func (s *Simulator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := s.Profile()
+		if p == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if d := s.delay(p); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if p.matchesFault(r.URL.Path) {
+			if s.roll(p.DropPercent) {
+				dropConnection(w)
+				return
+			}
+			if s.roll(p.ErrorPercent) {
+				status := p.ErrorStatus
+				if status == 0 {
+					status = http.StatusInternalServerError
+				}
+				w.Header().Set("X-Shinobi-Fault", p.Name)
+				http.Error(w, fmt.Sprintf("%d %s (injected by network profile %q)", status, http.StatusText(status), p.Name), status)
+				return
+			}
+		}
+
+		if p.DownKbps > 0 {
+			w = newThrottledWriter(w, p.DownKbps)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func dropConnection(w http.ResponseWriter) {
+	if hj, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			conn.Close()
+			return
+		}
+	}
+	// Makes net/http abort the connection without logging a stack trace
+	panic(http.ErrAbortHandler)
+}
+
+// throttledWriter paces writes to roughly the given bandwidth
+type throttledWriter struct {
+	http.ResponseWriter
+	bytesPerSec int
+}
+
+func newThrottledWriter(w http.ResponseWriter, kbps int) *throttledWriter {
+	return &throttledWriter{
+		ResponseWriter: w,
+		bytesPerSec:    kbps * 1000 / 8,
+	}
+}
+
+func (tw *throttledWriter) Write(b []byte) (int, error) {
+	// Send in 100ms worth of data at a time so the pacing is visible
+	chunk := tw.bytesPerSec / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+
+	written := 0
+	for written < len(b) {
+		end := written + chunk
+		if end > len(b) {
+			end = len(b)
+		}
+		n, err := tw.ResponseWriter.Write(b[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		tw.Flush()
+		time.Sleep(time.Duration(n) * time.Second / time.Duration(tw.bytesPerSec))
+	}
+	return written, nil
+}
+
+func (tw *throttledWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *throttledWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := tw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, fmt.Errorf("hijacking not supported")
+}
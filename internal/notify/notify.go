@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"sync"
+	"time"
+
+	"shinobi-webserver/internal/config"
+)
+
+// repeatAfter is how long the same notification about the same site is
+// held back
+const repeatAfter = 5 * time.Minute
+
+// Notifier decides which notifications reach the desktop: muted kinds and
+// quiet hours are dropped, repeats are held back and at most a set number
+// go out per hour.
+type Notifier struct {
+	settings func() config.Notifications
+	send     func(title, content string)
+
+	mu sync.Mutex
+	// When notifications went out in the last hour
+	sent []time.Time
+	// Last notification per kind and site
+	last map[string]time.Time
+	now  func() time.Time
+}
+
+// New returns a notifier reading its settings on every call, so changes
+// apply at once
+func New(settings func() config.Notifications, send func(title, content string)) *Notifier {
+	return &Notifier{
+		settings: settings,
+		send:     send,
+		last:     make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Notify sends a notification of the given kind about a site, keyed by its
+// ID, unless the settings hold it back. It reports whether it was sent.
+func (n *Notifier) Notify(kind, site, title, content string) bool {
+	settings := n.settings()
+	if !settings.Enabled(kind) {
+		return false
+	}
+
+	n.mu.Lock()
+	now := n.now()
+	if settings.Quiet(now) {
+		n.mu.Unlock()
+		return false
+	}
+
+	key := kind + "/" + site
+	if last, ok := n.last[key]; ok && now.Sub(last) < repeatAfter {
+		n.mu.Unlock()
+		return false
+	}
+
+	recent := n.sent[:0]
+	for _, t := range n.sent {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	n.sent = recent
+	if len(n.sent) >= settings.PerHour() {
+		n.mu.Unlock()
+		return false
+	}
+
+	n.sent = append(n.sent, now)
+	n.last[key] = now
+	n.mu.Unlock()
+
+	n.send(title, content)
+	return true
+}
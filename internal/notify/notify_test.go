@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"shinobi-webserver/internal/config"
+)
+
+type call struct {
+	kind, site string
+	// Minutes since the start of the test
+	at   int
+	want bool
+}
+
+func TestNotifier(t *testing.T) {
+	// A Monday at noon
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		settings config.Notifications
+		calls    []call
+	}{
+		{
+			name: "muted kind",
+			settings: config.Notifications{
+				Muted: []string{config.NotifyErrors},
+			},
+			calls: []call{
+				{config.NotifyErrors, "a", 0, false},
+				{config.NotifyCrash, "a", 0, true},
+			},
+		},
+		{
+			name: "repeats held back per kind and site",
+			calls: []call{
+				{config.NotifyCrash, "a", 0, true},
+				{config.NotifyCrash, "a", 1, false},
+				{config.NotifyRestart, "a", 1, true},
+				{config.NotifyCrash, "b", 1, true},
+				{config.NotifyCrash, "a", 5, true},
+			},
+		},
+		{
+			name:     "hourly limit",
+			settings: config.Notifications{MaxPerHour: 2},
+			calls: []call{
+				{config.NotifyCrash, "a", 0, true},
+				{config.NotifyCrash, "b", 10, true},
+				{config.NotifyCrash, "c", 20, false},
+				{config.NotifyCrash, "d", 59, false},
+				// The first one is an hour old now
+				{config.NotifyCrash, "e", 60, true},
+				{config.NotifyCrash, "f", 61, false},
+			},
+		},
+		{
+			name:     "default hourly limit",
+			settings: config.Notifications{},
+			calls: func() []call {
+				var calls []call
+				for i := 0; i < config.DefaultNotifyPerHour; i++ {
+					calls = append(calls, call{config.NotifyCrash, string(rune('a' + i)), i, true})
+				}
+				return append(calls, call{config.NotifyCrash, "z", 30, false})
+			}(),
+		},
+		{
+			name:     "quiet hours",
+			settings: config.Notifications{QuietFrom: "12:30", QuietUntil: "13:00"},
+			calls: []call{
+				{config.NotifyCrash, "a", 29, true},
+				{config.NotifyCrash, "b", 30, false},
+				{config.NotifyCrash, "c", 59, false},
+				{config.NotifyCrash, "d", 60, true},
+			},
+		},
+		{
+			name:     "dropped in quiet hours isn't held back later",
+			settings: config.Notifications{QuietFrom: "12:00", QuietUntil: "12:10"},
+			calls: []call{
+				{config.NotifyCrash, "a", 5, false},
+				{config.NotifyCrash, "a", 10, true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var now time.Time
+			sent := 0
+			n := New(func() config.Notifications { return tt.settings }, func(title, content string) { sent++ })
+			n.now = func() time.Time { return now }
+
+			wantSent := 0
+			for i, c := range tt.calls {
+				now = start.Add(time.Duration(c.at) * time.Minute)
+				if got := n.Notify(c.kind, c.site, "title", "content"); got != c.want {
+					t.Errorf("call %d (%s %s at +%dm) = %v, want %v", i, c.kind, c.site, c.at, got, c.want)
+				}
+				if c.want {
+					wantSent++
+				}
+			}
+			if sent != wantSent {
+				t.Errorf("sent %d notifications, want %d", sent, wantSent)
+			}
+		})
+	}
+}
+
+func TestSpikes(t *testing.T) {
+	tests := []struct {
+		name string
+		// Errors added before each observation, one a minute
+		errors []uint64
+		// Observations that report a spike
+		want []int
+	}{
+		{"quiet site", []uint64{0, 1, 2, 0, 3, 1}, nil},
+		{"short burst", []uint64{0, 50, 50, 0, 0}, nil},
+		{"lasting spike reported once", []uint64{0, 10, 12, 15, 20, 30}, []int{3}},
+		{"drop resets", []uint64{0, 10, 10, 2, 10, 10, 10}, []int{6}},
+		{"spike, calm, spike", []uint64{0, 10, 10, 10, 0, 10, 10, 10}, []int{3, 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSpikes()
+			start := time.Now()
+			var count uint64
+			var got []int
+			for i, added := range tt.errors {
+				count += added
+				if s.Observe("a", count, start.Add(time.Duration(i)*time.Minute), 10, 3) {
+					got = append(got, i)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("spikes at %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("spikes at %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSpikesRecreatedServer(t *testing.T) {
+	s := NewSpikes()
+	start := time.Now()
+	s.Observe("a", 100, start, 10, 1)
+	// A new server counts from zero again; that is no spike
+	if s.Observe("a", 5, start.Add(time.Minute), 10, 1) {
+		t.Error("reported a spike for a recreated server")
+	}
+	if !s.Observe("a", 20, start.Add(2*time.Minute), 10, 1) {
+		t.Error("missed a spike after the server was recreated")
+	}
+}
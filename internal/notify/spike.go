@@ -0,0 +1,57 @@
+package notify
+
+import "time"
+
+// Spikes watches error counts and tells when a site has answered with many
+// server errors for several minutes in a row
+type Spikes struct {
+	sites map[string]*spike
+}
+
+type spike struct {
+	// Count and time at the start of the current minute
+	count uint64
+	since time.Time
+	// Minutes in a row at or above the rate
+	minutes  int
+	reported bool
+}
+
+func NewSpikes() *Spikes {
+	return &Spikes{sites: make(map[string]*spike)}
+}
+
+// Observe records a site's total error count. It returns true once per
+// spike, when the site has answered at least rate errors a minute for the
+// given number of minutes.
+func (s *Spikes) Observe(id string, count uint64, now time.Time, rate, minutes int) bool {
+	sp, ok := s.sites[id]
+	if !ok || count < sp.count {
+		// New or recreated server
+		s.sites[id] = &spike{count: count, since: now}
+		return false
+	}
+	if now.Sub(sp.since) < time.Minute {
+		return false
+	}
+
+	if count-sp.count >= uint64(rate) {
+		sp.minutes++
+	} else {
+		sp.minutes = 0
+		sp.reported = false
+	}
+	sp.count = count
+	sp.since = now
+
+	if sp.minutes >= minutes && !sp.reported {
+		sp.reported = true
+		return true
+	}
+	return false
+}
+
+// Forget drops a site that is no longer running
+func (s *Spikes) Forget(id string) {
+	delete(s.sites, id)
+}
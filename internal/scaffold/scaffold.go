@@ -0,0 +1,251 @@
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"shinobi-webserver/internal/config"
+)
+
+//go:embed all:templates
+var builtin embed.FS
+
+// MetaFile describes a template; it is not copied into the site
+const MetaFile = "template.json"
+
+// Default is the template new sites start from
+const Default = "welcome"
+
+// Built-in templates in the order they are offered
+var builtinOrder = []string{"welcome", "blank", "html5", "spa", "docs"}
+
+type Template struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Entry file the template is built around; "" means index.html
+	EntryFile string `json:"entryFile,omitempty"`
+	BuiltIn   bool   `json:"builtIn"`
+
+	files fs.FS
+}
+
+// Entry returns the template's entry file
+func (t *Template) Entry() string {
+	if t.EntryFile == "" {
+		return "index.html"
+	}
+	return t.EntryFile
+}
+
+// Result lists the files Apply wrote and the ones it left alone because
+// they already existed, relative to the site folder
+type Result struct {
+	Written []string
+	Skipped []string
+}
+
+// Dir holds user templates, one folder per template
+func Dir() string {
+	return filepath.Join(config.Dir(), "templates")
+}
+
+// List returns the built-in templates followed by the user's. A user
+// template with a built-in name replaces it.
+func List() ([]*Template, error) {
+	user, err := userTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Template
+	for _, name := range builtinOrder {
+		if t, ok := user[name]; ok {
+			list = append(list, t)
+			delete(user, name)
+			continue
+		}
+		t, err := builtinTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+
+	names := make([]string, 0, len(user))
+	for name := range user {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		list = append(list, user[name])
+	}
+	return list, nil
+}
+
+// Get returns the template called name
+func Get(name string) (*Template, error) {
+	list, err := List()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range list {
+		if strings.EqualFold(t.Name, name) {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("template '%s' not found", name)
+}
+
+func builtinTemplate(name string) (*Template, error) {
+	sub, err := fs.Sub(builtin, "templates/"+name)
+	if err != nil {
+		return nil, err
+	}
+	return load(name, sub, true)
+}
+
+func userTemplates() (map[string]*Template, error) {
+	templates := map[string]*Template{}
+
+	entries, err := os.ReadDir(Dir())
+	if errors.Is(err, os.ErrNotExist) {
+		return templates, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		t, err := load(e.Name(), os.DirFS(filepath.Join(Dir(), e.Name())), false)
+		if err != nil {
+			return nil, err
+		}
+		templates[strings.ToLower(t.Name)] = t
+	}
+	return templates, nil
+}
+
+func load(name string, files fs.FS, builtIn bool) (*Template, error) {
+	t := &Template{Name: name, BuiltIn: builtIn, files: files}
+
+	data, err := fs.ReadFile(files, MetaFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("template '%s': %s: %v", name, MetaFile, err)
+	}
+	// The folder name is what users pick it by
+	t.Name = name
+	t.BuiltIn = builtIn
+	return t, nil
+}
+
+// Apply copies the template into the site's folder. Files that already
+// exist are never overwritten. {{site.name}}, {{site.port}} and
+// {{site.url}} are replaced in text files, and the template's entry file
+// is written under the site's entry file name.
+func (t *Template) Apply(site config.Site) (*Result, error) {
+	folder := site.FolderPath()
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return nil, err
+	}
+
+	port := strconv.Itoa(site.Port)
+	vars := strings.NewReplacer(
+		"{{site.name}}", site.Name,
+		"{{site.port}}", port,
+		"{{site.url}}", "http://localhost:"+port,
+	)
+
+	result := &Result{}
+	err := fs.WalkDir(t.files, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" || d.Name() == ".hg" || d.Name() == ".svn" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if p == MetaFile {
+			return nil
+		}
+
+		rel := p
+		if p == t.Entry() && site.EntryFile != "" {
+			// Keep the entry file inside the site folder
+			if entry := path.Clean("/" + filepath.ToSlash(site.EntryFile)); entry != "/" {
+				rel = strings.TrimPrefix(entry, "/")
+			}
+		}
+
+		data, err := fs.ReadFile(t.files, p)
+		if err != nil {
+			return err
+		}
+		if isText(data) {
+			data = []byte(vars.Replace(string(data)))
+		}
+
+		written, err := create(filepath.Join(folder, filepath.FromSlash(rel)), data)
+		if err != nil {
+			return err
+		}
+		if written {
+			result.Written = append(result.Written, rel)
+		} else {
+			result.Skipped = append(result.Skipped, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// create writes a new file, reporting false if it already exists
+func create(p string, data []byte) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// isText reports whether variables should be substituted in a file
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+}
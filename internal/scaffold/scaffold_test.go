@@ -0,0 +1,123 @@
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"shinobi-webserver/internal/config"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		// Files in the site folder before Apply
+		existing map[string]string
+		entry    string
+		written  []string
+		skipped  []string
+		// Files in the site folder after Apply
+		want map[string]string
+	}{
+		{
+			name:    "empty folder",
+			written: []string{"index.html"},
+		},
+		{
+			name:     "existing file is kept",
+			existing: map[string]string{"index.html": "mine"},
+			skipped:  []string{"index.html"},
+			want:     map[string]string{"index.html": "mine"},
+		},
+		{
+			name:     "other files are left alone",
+			existing: map[string]string{"notes.txt": "notes"},
+			written:  []string{"index.html"},
+			want:     map[string]string{"notes.txt": "notes"},
+		},
+		{
+			name:    "entry file renamed",
+			entry:   "pages/home.html",
+			written: []string{"pages/home.html"},
+		},
+		{
+			name:     "renamed entry file is kept",
+			existing: map[string]string{"home.html": "mine"},
+			entry:    "home.html",
+			skipped:  []string{"home.html"},
+			want:     map[string]string{"home.html": "mine"},
+		},
+		{
+			name:    "entry file can't leave the folder",
+			entry:   "../outside.html",
+			written: []string{"outside.html"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config.SetPath(filepath.Join(dir, "config.json"))
+			t.Cleanup(func() { config.SetPath("") })
+
+			folder := filepath.Join(dir, "site")
+			for name, content := range tt.existing {
+				p := filepath.Join(folder, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tmpl, err := Get("blank")
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := tmpl.Apply(config.Site{Name: "Docs", Port: 8123, Folder: folder, EntryFile: tt.entry})
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !reflect.DeepEqual(result.Written, tt.written) {
+				t.Errorf("written %v, want %v", result.Written, tt.written)
+			}
+			if !reflect.DeepEqual(result.Skipped, tt.skipped) {
+				t.Errorf("skipped %v, want %v", result.Skipped, tt.skipped)
+			}
+			for name, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(folder, filepath.FromSlash(name)))
+				if err != nil || string(data) != want {
+					t.Errorf("%s = %q, %v; want %q", name, data, err, want)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(dir, "outside.html")); err == nil {
+				t.Error("Apply wrote outside the site folder")
+			}
+		})
+	}
+}
+
+func TestApplyVariables(t *testing.T) {
+	dir := t.TempDir()
+	config.SetPath(filepath.Join(dir, "config.json"))
+	t.Cleanup(func() { config.SetPath("") })
+
+	tmpl, err := Get("blank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder := filepath.Join(dir, "site")
+	if _, err := tmpl.Apply(config.Site{Name: "Docs", Port: 8123, Folder: folder}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(folder, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<title>Docs</title>") {
+		t.Errorf("index.html = %q, want the site name in the title", data)
+	}
+}
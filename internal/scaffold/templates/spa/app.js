@@ -0,0 +1,24 @@
+const routes = {
+  "/": () => "<h1>{{site.name}}</h1><p>Served from {{site.url}}</p>",
+  "/about": () => "<h1>About</h1><p>Every route is handled in the browser.</p>",
+};
+
+function render() {
+  const view = routes[location.pathname];
+  document.getElementById("app").innerHTML = view
+    ? view()
+    : "<h1>Not Found</h1><p>No route for " + location.pathname + "</p>";
+}
+
+document.addEventListener("click", (event) => {
+  const link = event.target.closest("a[data-link]");
+  if (!link) {
+    return;
+  }
+  event.preventDefault();
+  history.pushState(null, "", link.getAttribute("href"));
+  render();
+});
+
+window.addEventListener("popstate", render);
+render();
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"shinobi-webserver/internal/siteconfig"
+)
+
+// ReloadLocal reads the project file in the site folder (shinobi.yaml and
+// friends) and applies it. An invalid file is reported to the error log
+// and the previous settings stay in effect.
+func (s *Server) ReloadLocal() {
+	settings, err := siteconfig.Load(s.Folder)
+	if err != nil {
+		var invalid *siteconfig.Error
+		if errors.As(err, &invalid) {
+			for _, p := range invalid.Problems {
+				s.logError(fmt.Sprintf("%s: %s", filepath.Base(invalid.File), p))
+			}
+			s.logError(fmt.Sprintf("Ignoring changes to %s until it is fixed", filepath.Base(invalid.File)))
+		} else {
+			s.logError(fmt.Sprintf("Failed to read project file: %v", err))
+		}
+		return
+	}
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	hadLocal := s.local != nil
+	if settings == nil {
+		s.local = nil
+	} else {
+		s.local = siteconfig.NewHandler(settings, s.Folder, s.entryFile)
+		s.local.LogError = s.logError
+	}
+	s.applyMarkdown()
+	s.applyTemplates()
+
+	switch {
+	case settings != nil:
+		s.logInfo(fmt.Sprintf("Applied %s", filepath.Base(settings.File)))
+	case hadLocal:
+		s.logInfo("Project file removed, using the central site settings")
+	}
+}
+
+// LocalSettings returns the project file settings in effect, or nil
+func (s *Server) LocalSettings() *siteconfig.Settings {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+
+	if s.local == nil {
+		return nil
+	}
+	return s.local.Settings
+}
+
+// watchLocal reloads the project file when it changes and returns a
+// function that stops watching
+func (s *Server) watchLocal() func() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		s.logError(fmt.Sprintf("Not watching the project file: %v", err))
+		return nil
+	}
+	// Editors often replace files, so watch the folder rather than the file
+	if err := watcher.Add(s.Folder); err != nil {
+		watcher.Close()
+		s.logError(fmt.Sprintf("Not watching the project file: %v", err))
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case <-done:
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !siteconfig.IsFileName(filepath.Base(event.Name)) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(250*time.Millisecond, s.ReloadLocal)
+
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		watcher.Close()
+	}
+}
+
+func (s *Server) localMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.stateMu.RLock()
+		local := s.local
+		s.stateMu.RUnlock()
+
+		if local == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		local.Middleware(next).ServeHTTP(w, r)
+	})
+}
@@ -1,26 +1,63 @@
 package server
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"shinobi-webserver/internal/config"
+	"shinobi-webserver/internal/har"
+	"shinobi-webserver/internal/markdown"
+	"shinobi-webserver/internal/netsim"
+	"shinobi-webserver/internal/siteconfig"
+	"shinobi-webserver/internal/templating"
 )
 
 type Server struct {
 	Port       int
 	Folder     string
+	LogsDir    string
 	httpServer *http.Server
 	logFile    *os.File
 	errorFile  *os.File
 	mu         sync.Mutex
 	ctx        context.Context
 	cancel     context.CancelFunc
-	Running    bool
+	// Set once the server answers, cleared when it stops; read it from
+	// any goroutine with IsRunning
+	running atomic.Bool
+
+	// OnCrash is called from the server's goroutine when it stops on its
+	// own after starting
+	OnCrash func(err error)
+	// Server errors (5xx) answered since the server was created
+	errorCount atomic.Uint64
+
+	// Request handling features, switchable while running
+	stateMu   sync.RWMutex
+	recorder  *har.Recorder
+	captured  *har.HAR
+	replayer  *har.Replayer
+	netsim    *netsim.Simulator
+	markdown  *markdown.Renderer
+	templates *templating.Engine
+	local     *siteconfig.Handler
+
+	// Options from the central config; the project file may override them
+	entryFile     string
+	siteMarkdown  bool
+	siteLayout    string
+	siteTemplates bool
+
+	stopWatch func()
 }
 
 func New(port int, folder string) *Server {
@@ -28,22 +65,49 @@ func New(port int, folder string) *Server {
 	return &Server{
 		Port:    port,
 		Folder:  folder,
+		LogsDir: filepath.Join(folder, "logs"),
 		ctx:     ctx,
 		cancel:  cancel,
-		Running: false,
+		netsim:  netsim.New(),
+	}
+}
+
+// NewForSite creates a server with the site's options applied. profiles
+// are the user-defined network profiles the site may refer to.
+func NewForSite(site *config.Site, profiles []netsim.Profile) (*Server, error) {
+	s := New(site.Port, site.FolderPath())
+	s.LogsDir = site.LogsPath()
+	s.entryFile = site.EntryFile
+
+	if site.HARMock != "" {
+		if err := s.LoadHAR(site.HARMock, site.HARMatchBody); err != nil {
+			return nil, err
+		}
 	}
+	if site.NetworkProfile != "" {
+		p := netsim.Find(site.NetworkProfile, profiles)
+		if p == nil {
+			return nil, fmt.Errorf("unknown network profile %q", site.NetworkProfile)
+		}
+		s.SetNetworkProfile(p)
+	}
+	s.SetMarkdown(site.Markdown, site.MarkdownLayout)
+	s.SetTemplates(site.Templates)
+	s.ReloadLocal()
+
+	return s, nil
 }
 
 func (s *Server) Start() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.Running {
+	if s.running.Load() {
 		return fmt.Errorf("server is already running")
 	}
 
 	// Setup logging
-	logsDir := filepath.Join(s.Folder, "logs")
+	logsDir := s.LogsDir
 	os.MkdirAll(logsDir, 0755)
 
 	var err error
@@ -68,43 +132,68 @@ func (s *Server) Start() error {
 		return err
 	}
 
-	// Create file server with logging
-	fs := http.FileServer(http.Dir(s.Folder))
-	handler := s.loggingMiddleware(fs)
+	// Re-read the project file so problems reach the error log, and apply
+	// edits to it while running
+	s.ReloadLocal()
+	s.stopWatch = s.watchLocal()
 
 	s.httpServer = &http.Server{
 		Addr:    fmt.Sprintf(":%d", s.Port),
-		Handler: handler,
+		Handler: s.handler(),
 	}
 
-	// Start server in goroutine
+	// Start server in goroutine; exited gets its error, or is closed when
+	// the server is shut down
+	exited := make(chan error, 1)
 	go func() {
+		defer close(exited)
 		s.logInfo(fmt.Sprintf("Server starting on port %d", s.Port))
 		s.logInfo(fmt.Sprintf("Serving files from: %s", s.Folder))
 		if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
 			s.logError(fmt.Sprintf("Server error: %v", err))
-			s.Running = false
+			s.running.Store(false)
+			exited <- err
 		}
 	}()
 
 	// Wait a moment to ensure server is up
 	time.Sleep(100 * time.Millisecond)
 
-	// Check if server is reachable
-	if err := s.checkServer(); err != nil {
-		s.Running = false
+	// Check if server is reachable. Another program on the port would
+	// answer, so a listen error counts first.
+	select {
+	case err = <-exited:
+	default:
+		err = s.checkServer()
+	}
+	if err != nil {
+		s.running.Store(false)
+		if s.stopWatch != nil {
+			s.stopWatch()
+			s.stopWatch = nil
+		}
 		return fmt.Errorf("server failed to start: %v", err)
 	}
 
-	s.Running = true
+	s.running.Store(true)
+	go func() {
+		if err, crashed := <-exited; crashed && s.OnCrash != nil {
+			s.OnCrash(err)
+		}
+	}()
 	return nil
 }
 
+// ErrorCount returns how many server errors (5xx) were answered so far
+func (s *Server) ErrorCount() uint64 {
+	return s.errorCount.Load()
+}
+
 func (s *Server) Stop() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.Running {
+	if !s.running.Load() {
 		return fmt.Errorf("server is not running")
 	}
 
@@ -119,6 +208,10 @@ func (s *Server) Stop() error {
 		s.logInfo("Server stopped gracefully")
 	}
 
+	if s.stopWatch != nil {
+		s.stopWatch()
+		s.stopWatch = nil
+	}
 	if s.logFile != nil {
 		s.logFile.Close()
 	}
@@ -127,10 +220,35 @@ func (s *Server) Stop() error {
 	}
 
 	s.cancel()
-	s.Running = false
+	s.running.Store(false)
 	return nil
 }
 
+// IsRunning reports whether the server is serving; it turns false when the
+// server stops on its own
+func (s *Server) IsRunning() bool {
+	return s.running.Load()
+}
+
+// Handler returns what the site serves: its files with Markdown and
+// template processing and the project file's rules, without logging,
+// simulation or traffic capture
+func (s *Server) Handler() http.Handler {
+	var h http.Handler = http.FileServer(http.Dir(s.Folder))
+	h = s.markdownMiddleware(h)
+	h = s.templatesMiddleware(h)
+	return s.localMiddleware(h)
+}
+
+func (s *Server) handler() http.Handler {
+	// Wrap the site with replay, recording, network simulation and logging
+	h := s.Handler()
+	h = s.replayMiddleware(h)
+	h = s.recordMiddleware(h)
+	h = s.netsim.Middleware(h)
+	return s.loggingMiddleware(h)
+}
+
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -140,6 +258,10 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(rw, r)
 
+		if rw.status >= 500 {
+			s.errorCount.Add(1)
+		}
+
 		duration := time.Since(start)
 		logMsg := fmt.Sprintf("[%s] %s %s %d - %v\n",
 			start.Format("2006-01-02 15:04:05"),
@@ -172,16 +294,213 @@ func (s *Server) logError(msg string) {
 }
 
 func (s *Server) checkServer() error {
-	// Try to connect to the server
-	url := fmt.Sprintf("http://localhost:%d", s.Port)
-	resp, err := http.Get(url)
+	// Try to connect to the server. A plain TCP dial keeps the check out of
+	// network simulation, HAR recording and replay.
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("localhost:%d", s.Port), 2*time.Second)
 	if err != nil {
 		return err
 	}
-	resp.Body.Close()
+	conn.Close()
 	return nil
 }
 
+// StartRecording begins capturing traffic, discarding any previous capture
+func (s *Server) StartRecording() {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.recorder = har.NewRecorder()
+	s.logInfo("HAR recording started")
+}
+
+// StopRecording stops capturing but keeps what was captured for export
+func (s *Server) StopRecording() *har.HAR {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	if s.recorder == nil {
+		return nil
+	}
+	h := s.recorder.HAR()
+	s.recorder = nil
+	s.captured = h
+	s.logInfo(fmt.Sprintf("HAR recording stopped (%d entries)", len(h.Log.Entries)))
+	return h
+}
+
+func (s *Server) IsRecording() bool {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.recorder != nil
+}
+
+// ExportHAR writes the current or last finished capture to path
+func (s *Server) ExportHAR(path string) error {
+	s.stateMu.RLock()
+	h := s.captured
+	if s.recorder != nil {
+		h = s.recorder.HAR()
+	}
+	s.stateMu.RUnlock()
+
+	if h == nil {
+		return fmt.Errorf("no traffic has been recorded")
+	}
+	if err := h.Save(path); err != nil {
+		return err
+	}
+
+	s.logInfo(fmt.Sprintf("Exported %d HAR entries to %s", len(h.Log.Entries), path))
+	return nil
+}
+
+// LoadHAR serves the responses recorded in path as a mock. Requests without
+// a recorded response fall through to the site's files.
+func (s *Server) LoadHAR(path string, matchBody bool) error {
+	rp, err := har.LoadReplayer(path, matchBody)
+	if err != nil {
+		return fmt.Errorf("failed to load HAR: %v", err)
+	}
+
+	s.stateMu.Lock()
+	s.replayer = rp
+	s.stateMu.Unlock()
+
+	s.logInfo(fmt.Sprintf("Replaying %d HAR entries from %s", rp.Len(), path))
+	return nil
+}
+
+func (s *Server) ClearHAR() {
+	s.stateMu.Lock()
+	s.replayer = nil
+	s.stateMu.Unlock()
+}
+
+// SetNetworkProfile switches network simulation, also while running. A nil
+// profile serves at full speed.
+func (s *Server) SetNetworkProfile(p *netsim.Profile) {
+	s.netsim.SetProfile(p)
+	if p != nil {
+		s.logInfo(fmt.Sprintf("Network profile set to %s", p.Name))
+	} else {
+		s.logInfo("Network simulation disabled")
+	}
+}
+
+func (s *Server) NetworkProfile() *netsim.Profile {
+	return s.netsim.Profile()
+}
+
+// SetMarkdown turns on-the-fly Markdown rendering on or off. layout is an
+// optional html/template file relative to the site folder.
+func (s *Server) SetMarkdown(enabled bool, layout string) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	s.siteMarkdown, s.siteLayout = enabled, layout
+	s.applyMarkdown()
+}
+
+// SetTemplates turns html/template processing of .html files on or off
+func (s *Server) SetTemplates(enabled bool) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	s.siteTemplates = enabled
+	s.applyTemplates()
+}
+
+// applyMarkdown sets up rendering from the site option and the project
+// file's override. Callers hold stateMu.
+func (s *Server) applyMarkdown() {
+	enabled, layout := s.siteMarkdown, s.siteLayout
+	if s.local != nil {
+		if s.local.Settings.Markdown != nil {
+			enabled = *s.local.Settings.Markdown
+		}
+		if s.local.Settings.MarkdownLayout != "" {
+			layout = s.local.Settings.MarkdownLayout
+		}
+	}
+
+	if !enabled {
+		s.markdown = nil
+		return
+	}
+	s.markdown = markdown.New(s.Folder, layout)
+	s.markdown.LogError = s.logError
+}
+
+// applyTemplates is applyMarkdown for template processing
+func (s *Server) applyTemplates() {
+	enabled := s.siteTemplates
+	if s.local != nil && s.local.Settings.Templates != nil {
+		enabled = *s.local.Settings.Templates
+	}
+
+	if !enabled {
+		s.templates = nil
+		return
+	}
+	s.templates = templating.New(s.Folder)
+	s.templates.LogError = s.logError
+}
+
+func (s *Server) recordMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.stateMu.RLock()
+		rec := s.recorder
+		s.stateMu.RUnlock()
+
+		if rec == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec.Middleware(next).ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) replayMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.stateMu.RLock()
+		rp := s.replayer
+		s.stateMu.RUnlock()
+
+		if rp == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rp.Middleware(next).ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) markdownMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.stateMu.RLock()
+		md := s.markdown
+		s.stateMu.RUnlock()
+
+		if md == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		md.Middleware(next).ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) templatesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.stateMu.RLock()
+		t := s.templates
+		s.stateMu.RUnlock()
+
+		if t == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		t.Middleware(next).ServeHTTP(w, r)
+	})
+}
+
 // Helper struct to capture response status
 type responseWriter struct {
 	http.ResponseWriter
@@ -192,3 +511,16 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.status = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, fmt.Errorf("hijacking not supported")
+}
@@ -0,0 +1,164 @@
+package siteconfig
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Handler applies a site's project settings to its requests
+type Handler struct {
+	Settings  *Settings
+	Root      string
+	EntryFile string
+	LogError  func(string)
+
+	proxies []*httputil.ReverseProxy
+}
+
+// NewHandler prepares settings that passed Validate for serving root
+func NewHandler(s *Settings, root, entryFile string) *Handler {
+	h := &Handler{Settings: s, Root: root, EntryFile: entryFile}
+
+	for _, rule := range s.Proxy {
+		target, _ := url.Parse(rule.Target)
+		rule := rule
+
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		director := proxy.Director
+		proxy.Director = func(r *http.Request) {
+			if rule.StripPrefix {
+				r.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, rule.Path), "/")
+				r.URL.RawPath = ""
+			}
+			director(r)
+			// Backends usually route on their own host name
+			r.Host = target.Host
+		}
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			h.logError("proxy " + r.URL.Path + " → " + rule.Target + ": " + err.Error())
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		}
+		h.proxies = append(h.proxies, proxy)
+	}
+	return h
+}
+
+func (h *Handler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := h.Settings
+		p := r.URL.Path
+
+		// The project file may name internal hosts; it isn't content
+		if path.Dir(p) == "/" && IsFileName(path.Base(p)) {
+			http.NotFound(w, r)
+			return
+		}
+
+		for _, rd := range s.Redirects {
+			if splat, ok := match(rd.From, p); ok {
+				to := strings.ReplaceAll(rd.To, ":splat", splat)
+				if r.URL.RawQuery != "" && !strings.Contains(to, "?") {
+					to += "?" + r.URL.RawQuery
+				}
+				status := rd.Status
+				if status == 0 {
+					status = http.StatusMovedPermanently
+				}
+				http.Redirect(w, r, to, status)
+				return
+			}
+		}
+
+		// Proxied responses keep the backend's headers
+		for i, rule := range s.Proxy {
+			if p == rule.Path || strings.HasPrefix(p, strings.TrimSuffix(rule.Path, "/")+"/") {
+				h.proxies[i].ServeHTTP(w, r)
+				return
+			}
+		}
+
+		h.setHeaders(w.Header(), p)
+
+		if s.SPA && h.fallback(r) {
+			r2 := r.Clone(r.Context())
+			r2.URL.Path = h.entryPath()
+			next.ServeHTTP(w, r2)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) setHeaders(header http.Header, p string) {
+	s := h.Settings
+
+	if s.Cache != nil {
+		value := s.Cache.Default
+		for _, rule := range s.Cache.Rules {
+			if _, ok := match(rule.Path, p); ok {
+				value = rule.Value()
+				break
+			}
+		}
+		if value != "" {
+			header.Set("Cache-Control", value)
+		}
+	}
+
+	// Explicit headers win over the cache policy
+	for _, rule := range s.Headers {
+		if _, ok := match(rule.Path, p); ok {
+			for name, value := range rule.Set {
+				header.Set(name, value)
+			}
+		}
+	}
+}
+
+// fallback reports whether an SPA route should be answered with the entry
+// file: a page request for something that isn't on disk
+func (h *Handler) fallback(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	// Missing assets should stay 404s
+	if path.Ext(r.URL.Path) != "" {
+		return false
+	}
+
+	file := filepath.Join(h.Root, filepath.FromSlash(path.Clean(r.URL.Path)))
+	for _, candidate := range []string{file, file + ".html", file + ".md"} {
+		if _, err := os.Stat(candidate); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// entryPath is the URL of the entry file; index files are requested as
+// their directory so FileServer doesn't redirect
+func (h *Handler) entryPath() string {
+	entry := "/" + strings.TrimPrefix(filepath.ToSlash(h.EntryFile), "/")
+	if entry == "/" {
+		return "/"
+	}
+	if path.Base(entry) == "index.html" {
+		if dir := path.Dir(entry); dir != "/" {
+			return dir + "/"
+		}
+		return "/"
+	}
+	return entry
+}
+
+func (h *Handler) logError(msg string) {
+	if h.LogError != nil {
+		h.LogError(msg)
+	}
+}
@@ -0,0 +1,274 @@
+package siteconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames are the project files looked for in a site folder, in order
+// of preference. shinobi.json also holds the site definition that "open
+// folder as site" reads.
+var FileNames = []string{"shinobi.yaml", "shinobi.yml", "shinobi.json"}
+
+// Settings are per-site options kept alongside the content. They are
+// merged over the site's entry in the central config.
+type Settings struct {
+	// Override the site's central options when set
+	Markdown       *bool  `json:"markdown,omitempty"`
+	MarkdownLayout string `json:"markdownLayout,omitempty"`
+	Templates      *bool  `json:"templates,omitempty"`
+
+	Headers   []HeaderRule `json:"headers,omitempty"`
+	Redirects []Redirect   `json:"redirects,omitempty"`
+	Proxy     []ProxyRule  `json:"proxy,omitempty"`
+	Cache     *CachePolicy `json:"cache,omitempty"`
+
+	// Serve the entry file for paths that match no file, for client-side
+	// routing
+	SPA bool `json:"spa,omitempty"`
+
+	// File the settings were read from
+	File string `json:"-"`
+}
+
+// HeaderRule sets response headers on paths matching Path
+type HeaderRule struct {
+	Path string            `json:"path"`
+	Set  map[string]string `json:"set"`
+}
+
+// Redirect sends From to To. A From ending in /* matches everything below
+// it, and :splat in To is replaced with the matched rest.
+type Redirect struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status,omitempty"`
+}
+
+// ProxyRule forwards requests below Path to Target, e.g. an API server
+type ProxyRule struct {
+	Path        string `json:"path"`
+	Target      string `json:"target"`
+	StripPrefix bool   `json:"stripPrefix,omitempty"`
+}
+
+// CachePolicy sets Cache-Control. Default applies to responses no rule
+// matches; empty leaves the header alone.
+type CachePolicy struct {
+	Default string      `json:"default,omitempty"`
+	Rules   []CacheRule `json:"rules,omitempty"`
+}
+
+type CacheRule struct {
+	Path      string `json:"path"`
+	MaxAge    int    `json:"maxAge,omitempty"`
+	Immutable bool   `json:"immutable,omitempty"`
+	NoStore   bool   `json:"noStore,omitempty"`
+}
+
+// Value is the Cache-Control header for the rule
+func (r CacheRule) Value() string {
+	if r.NoStore {
+		return "no-store"
+	}
+	v := fmt.Sprintf("public, max-age=%d", r.MaxAge)
+	if r.Immutable {
+		v += ", immutable"
+	}
+	return v
+}
+
+// Keys of the site definition, read by "open folder as site" rather than
+// here
+var definitionKeys = map[string]bool{
+	"id": true, "name": true, "folder": true, "port": true, "entryFile": true, "lastStarted": true,
+	"harMock": true, "harMatchBody": true, "networkProfile": true, "logsFolder": true,
+	"running": true, "autoStart": true, "startOrder": true, "dependsOn": true,
+}
+
+// Find returns the project file in folder, or "" if there is none
+func Find(folder string) string {
+	for _, name := range FileNames {
+		p := filepath.Join(folder, name)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+// IsFileName reports whether name is one of FileNames
+func IsFileName(name string) bool {
+	for _, n := range FileNames {
+		if strings.EqualFold(name, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads the project file in folder. It returns nil settings when
+// there is none, and an *Error when the file is unusable.
+func Load(folder string) (*Settings, error) {
+	p := Find(folder)
+	if p == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data, p)
+	if err != nil {
+		return nil, err
+	}
+	if problems := s.Validate(); len(problems) > 0 {
+		return nil, &Error{File: p, Problems: problems}
+	}
+	return s, nil
+}
+
+// Parse decodes a project file; file names it and picks YAML or JSON
+func Parse(data []byte, file string) (*Settings, error) {
+	if ext := strings.ToLower(filepath.Ext(file)); ext == ".yaml" || ext == ".yml" {
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, &Error{File: file, Problems: []string{err.Error()}}
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, &Error{File: file, Problems: []string{err.Error()}}
+		}
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, &Error{File: file, Problems: []string{err.Error()}}
+	}
+	for key := range raw {
+		if definitionKeys[key] {
+			delete(raw, key)
+		}
+	}
+	rest, _ := json.Marshal(raw)
+
+	var s Settings
+	dec := json.NewDecoder(bytes.NewReader(rest))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, &Error{File: file, Problems: []string{err.Error()}}
+	}
+	s.File = file
+	return &s, nil
+}
+
+// Error lists what is wrong with a project file
+type Error struct {
+	File     string
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.File, strings.Join(e.Problems, "; "))
+}
+
+// Validate returns a message per problem found
+func (s *Settings) Validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	checkPath := func(field, p string) {
+		if p == "" {
+			add("%s is empty", field)
+			return
+		}
+		if !strings.HasPrefix(p, "/") && strings.Contains(p, "/") {
+			add("%s %q must start with / or be a file pattern like *.css", field, p)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			add("%s %q is not a valid pattern", field, p)
+		}
+	}
+
+	for i, h := range s.Headers {
+		checkPath(fmt.Sprintf("headers[%d].path", i), h.Path)
+		if len(h.Set) == 0 {
+			add("headers[%d].set is empty", i)
+		}
+		for name := range h.Set {
+			if name == "" || strings.ContainsAny(name, " \t:\r\n") {
+				add("headers[%d].set: invalid header name %q", i, name)
+			}
+		}
+	}
+
+	for i, r := range s.Redirects {
+		checkPath(fmt.Sprintf("redirects[%d].from", i), r.From)
+		if r.To == "" {
+			add("redirects[%d].to is empty", i)
+		}
+		switch r.Status {
+		case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+			http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			add("redirects[%d].status %d is not a redirect status", i, r.Status)
+		}
+	}
+
+	for i, p := range s.Proxy {
+		if !strings.HasPrefix(p.Path, "/") {
+			add("proxy[%d].path %q must start with /", i, p.Path)
+		}
+		u, err := url.Parse(p.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("proxy[%d].target %q must be an http or https URL", i, p.Target)
+		}
+	}
+
+	if s.Cache != nil {
+		for i, r := range s.Cache.Rules {
+			checkPath(fmt.Sprintf("cache.rules[%d].path", i), r.Path)
+			if r.MaxAge < 0 {
+				add("cache.rules[%d].maxAge must not be negative", i)
+			}
+		}
+	}
+
+	if strings.Contains(s.MarkdownLayout, "..") {
+		add("markdownLayout %q points outside the site folder", s.MarkdownLayout)
+	}
+	return problems
+}
+
+// match reports whether urlPath matches pattern, returning what a
+// trailing /* matched. Patterns without a slash match the file name.
+func match(pattern, urlPath string) (string, bool) {
+	if strings.HasSuffix(pattern, "/*") {
+		prefix := strings.TrimSuffix(pattern, "*")
+		if urlPath+"/" == prefix {
+			return "", true
+		}
+		if strings.HasPrefix(urlPath, prefix) {
+			return strings.TrimPrefix(urlPath, prefix), true
+		}
+		return "", false
+	}
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(urlPath))
+		return "", ok
+	}
+	ok, _ := path.Match(pattern, urlPath)
+	return "", ok
+}
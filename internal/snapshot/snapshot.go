@@ -0,0 +1,460 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"shinobi-webserver/internal/bundle"
+	"shinobi-webserver/internal/config"
+)
+
+const (
+	manifestFile = "snapshot.json"
+	contentDir   = "content"
+)
+
+// Snapshot is a site's folder and definition at one point in time, kept as
+// a zip archive
+type Snapshot struct {
+	ID        string      `json:"id"`
+	Site      config.Site `json:"site"`
+	Workspace string      `json:"workspace"`
+	Created   time.Time   `json:"created"`
+	Note      string      `json:"note,omitempty"`
+	// Taken automatically before a bulk change
+	Auto bool `json:"auto,omitempty"`
+	// Files and their total size before compression
+	Files int   `json:"files"`
+	Size  int64 `json:"size"`
+
+	// Size of the archive on disk
+	Archive int64 `json:"archive"`
+	path    string
+}
+
+// Diff lists how a site's folder differs from a snapshot, relative to the
+// folder
+type Diff struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// Empty reports whether the folder still matches the snapshot
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Dir holds snapshots, in a folder per site ID
+func Dir() string {
+	return filepath.Join(config.Dir(), "snapshots")
+}
+
+func siteDir(id string) string {
+	return filepath.Join(Dir(), id)
+}
+
+// adopt moves snapshots kept by workspace and site name, as earlier
+// versions did, to the site's ID folder
+func adopt(workspace string, site config.Site) {
+	legacy := filepath.Join(Dir(), bundle.Slug(workspace), bundle.Slug(site.Name))
+	if exists(legacy) && !exists(siteDir(site.ID)) {
+		os.Rename(legacy, siteDir(site.ID))
+	}
+}
+
+// Create archives the site's folder, leaving out logs and version control
+// data
+func Create(site config.Site, workspace, note string, auto bool) (*Snapshot, error) {
+	folder := site.FolderPath()
+	if info, err := os.Stat(folder); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a folder", folder)
+	}
+
+	adopt(workspace, site)
+	dir := siteDir(site.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	id := now.Format("20060102-150405")
+	p := filepath.Join(dir, id+".zip")
+	for i := 2; exists(p); i++ {
+		id = fmt.Sprintf("%s-%d", now.Format("20060102-150405"), i)
+		p = filepath.Join(dir, id+".zip")
+	}
+
+	site.LastStarted = time.Time{}
+	snap := &Snapshot{
+		ID:        id,
+		Site:      site,
+		Workspace: workspace,
+		Created:   now.UTC().Truncate(time.Second),
+		Note:      note,
+		Auto:      auto,
+		path:      p,
+	}
+	if err := write(snap, folder); err != nil {
+		os.Remove(p)
+		return nil, err
+	}
+	if info, err := os.Stat(p); err == nil {
+		snap.Archive = info.Size()
+	}
+	return snap, nil
+}
+
+func write(snap *Snapshot, folder string) error {
+	f, err := os.OpenFile(snap.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+
+	err = walk(folder, func(rel, p string, info fs.FileInfo) error {
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = contentDir + "/" + rel
+		header.Method = zip.Deflate
+
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		if _, err := io.Copy(w, src); err != nil {
+			return err
+		}
+		snap.Files++
+		snap.Size += info.Size()
+		return nil
+	})
+
+	// The manifest goes last so it can carry the totals
+	if err == nil {
+		var data []byte
+		if data, err = json.MarshalIndent(snap, "", "  "); err == nil {
+			var w io.Writer
+			if w, err = zw.Create(manifestFile); err == nil {
+				_, err = w.Write(data)
+			}
+		}
+	}
+
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// List returns the site's snapshots, newest first
+func List(workspace string, site config.Site) ([]*Snapshot, error) {
+	adopt(workspace, site)
+	dir := siteDir(site.ID)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snaps []*Snapshot
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".zip") {
+			continue
+		}
+		snap, err := read(filepath.Join(dir, e.Name()))
+		if err != nil {
+			// Half written or not ours
+			continue
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].ID > snaps[j].ID
+	})
+	return snaps, nil
+}
+
+// Get returns one of the site's snapshots by ID
+func Get(workspace string, site config.Site, id string) (*Snapshot, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("invalid snapshot %q", id)
+	}
+	adopt(workspace, site)
+	snap, err := read(filepath.Join(siteDir(site.ID), id+".zip"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("site '%s' has no snapshot %s", site.Name, id)
+	}
+	return snap, err
+}
+
+func read(p string) (*Snapshot, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	f, err := zr.Open(manifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: not a snapshot", p)
+	}
+	defer f.Close()
+
+	var snap Snapshot
+	if err := json.NewDecoder(f).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	snap.ID = strings.TrimSuffix(filepath.Base(p), ".zip")
+	snap.path = p
+	if info, err := os.Stat(p); err == nil {
+		snap.Archive = info.Size()
+	}
+	return &snap, nil
+}
+
+// Remove deletes the snapshot
+func (s *Snapshot) Remove() error {
+	return os.Remove(s.path)
+}
+
+// Diff compares the folder with the snapshot's content
+func (s *Snapshot) Diff(folder string) (*Diff, error) {
+	zr, err := zip.OpenReader(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	saved := map[string]*zip.File{}
+	for _, f := range zr.File {
+		if rel, ok := contentPath(f.Name); ok {
+			saved[rel] = f
+		}
+	}
+
+	d := &Diff{Added: []string{}, Removed: []string{}, Modified: []string{}}
+	err = walk(folder, func(rel, p string, info fs.FileInfo) error {
+		f, ok := saved[rel]
+		if !ok {
+			d.Added = append(d.Added, rel)
+			return nil
+		}
+		delete(saved, rel)
+
+		if uint64(info.Size()) != f.UncompressedSize64 {
+			d.Modified = append(d.Modified, rel)
+			return nil
+		}
+		sum, err := checksum(p)
+		if err != nil {
+			return err
+		}
+		if sum != f.CRC32 {
+			d.Modified = append(d.Modified, rel)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	for rel := range saved {
+		d.Removed = append(d.Removed, rel)
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Modified)
+	return d, nil
+}
+
+// Restore puts the folder back to the snapshot's content and the site's
+// settings back to what they were. The site keeps its current name, folder
+// and port. Logs and version control data in the folder are left alone.
+func (s *Snapshot) Restore(cfg *config.Config, id string) error {
+	current := cfg.GetSite(id)
+	if current == nil {
+		return fmt.Errorf("site %s not found", id)
+	}
+	zr, err := zip.OpenReader(s.path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	// Check every entry before touching the folder, so a bad one can't
+	// leave it half restored
+	for _, f := range zr.File {
+		if rel, ok := contentPath(f.Name); ok && !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return fmt.Errorf("snapshot entry %s points outside the site folder", f.Name)
+		}
+	}
+
+	folder := current.FolderPath()
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+
+	keep := map[string]bool{}
+	for _, f := range zr.File {
+		rel, ok := contentPath(f.Name)
+		if !ok {
+			continue
+		}
+		keep[rel] = true
+		if err := extract(f, filepath.Join(folder, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+	}
+
+	// Drop files added since
+	var added []string
+	walk(folder, func(rel, p string, _ fs.FileInfo) error {
+		if !keep[rel] {
+			added = append(added, p)
+		}
+		return nil
+	})
+	for _, p := range added {
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+	removeEmptyDirs(folder)
+
+	restored := s.Site
+	restored.ID = current.ID
+	restored.Name = current.Name
+	restored.Folder = current.Folder
+	restored.Port = current.Port
+	restored.LogsFolder = current.LogsFolder
+	restored.LastStarted = current.LastStarted
+	restored.Running = current.Running
+	return cfg.UpdateSite(id, restored)
+}
+
+func contentPath(name string) (string, bool) {
+	if !strings.HasPrefix(name, contentDir+"/") || strings.HasSuffix(name, "/") {
+		return "", false
+	}
+	return path.Clean(strings.TrimPrefix(name, contentDir+"/")), true
+}
+
+func extract(f *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	mode := f.Mode().Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// walk calls fn for the regular files in a site folder, skipping logs and
+// version control data
+func walk(folder string, fn func(rel, p string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(folder, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(folder, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel == "logs" || d.Name() == ".git" || d.Name() == ".hg" || d.Name() == ".svn" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(rel, p, info)
+	})
+}
+
+// removeEmptyDirs deletes folders left empty by Restore, deepest first
+func removeEmptyDirs(folder string) {
+	var dirs []string
+	filepath.WalkDir(folder, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && p != folder {
+			if d.Name() == "logs" || d.Name() == ".git" || d.Name() == ".hg" || d.Name() == ".svn" {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
+
+func checksum(p string) (uint32, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, f); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
+
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
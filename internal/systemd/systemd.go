@@ -0,0 +1,127 @@
+package systemd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"shinobi-webserver/internal/config"
+)
+
+// ManagerUnit serves every site set to start on launch
+const ManagerUnit = "shinobi-webserver.service"
+
+// Unit is a systemd user service running the headless server
+type Unit struct {
+	Name             string
+	Description      string
+	WorkingDirectory string
+	// Command line, the executable first
+	ExecStart []string
+	// Journal tag for the service's output
+	Identifier string
+}
+
+// SiteUnitName is stable across renames since it uses the site's ID
+func SiteUnitName(site config.Site) string {
+	return "shinobi-site-" + site.ID + ".service"
+}
+
+// ForSite returns a unit serving one site from its folder
+func ForSite(site config.Site, exe, configPath string) Unit {
+	return Unit{
+		Name:             SiteUnitName(site),
+		Description:      fmt.Sprintf("Shinobi Web Server site %s (port %d)", site.Name, site.Port),
+		WorkingDirectory: site.FolderPath(),
+		ExecStart:        []string{exe, "--config", configPath, "serve", site.ID},
+		Identifier:       "shinobi-" + site.ID,
+	}
+}
+
+// ForManager returns a unit serving the sites set to start on launch
+func ForManager(exe, configPath string) Unit {
+	return Unit{
+		Name:             ManagerUnit,
+		Description:      "Shinobi Web Server",
+		WorkingDirectory: filepath.Dir(configPath),
+		ExecStart:        []string{exe, "--config", configPath, "serve"},
+		Identifier:       "shinobi-webserver",
+	}
+}
+
+// String renders the unit file
+func (u Unit) String() string {
+	args := make([]string, len(u.ExecStart))
+	for i, arg := range u.ExecStart {
+		args[i] = quote(arg)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", escape(u.Description))
+	fmt.Fprintf(&b, "After=network.target\n\n")
+	fmt.Fprintf(&b, "[Service]\n")
+	fmt.Fprintf(&b, "Type=simple\n")
+	// Taken whole, so spaces need no quoting
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", escape(u.WorkingDirectory))
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(args, " "))
+	fmt.Fprintf(&b, "Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=5\n")
+	fmt.Fprintf(&b, "StandardOutput=journal\n")
+	fmt.Fprintf(&b, "StandardError=journal\n")
+	fmt.Fprintf(&b, "SyslogIdentifier=%s\n\n", u.Identifier)
+	fmt.Fprintf(&b, "[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=default.target\n")
+	return b.String()
+}
+
+// escape keeps systemd from expanding specifiers in free text
+func escape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// quote makes one ExecStart argument safe from splitting, specifier and
+// variable expansion
+func quote(arg string) string {
+	arg = strings.NewReplacer("%", "%%", "$", "$$").Replace(arg)
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\;") {
+		return arg
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// Dir is where user units are installed, $XDG_CONFIG_HOME/systemd/user
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user"), nil
+}
+
+// Write saves the units into dir and returns their paths
+func Write(units []Unit, dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, u := range units {
+		p := filepath.Join(dir, u.Name)
+		if err := os.WriteFile(p, []byte(u.String()), 0644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// Systemctl runs systemctl for the user's service manager
+func Systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl --user %s: %v\n%s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package systemd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// required lists the settings every generated unit has, by section
+var required = map[string][]string{
+	"Unit":    {"Description"},
+	"Service": {"ExecStart", "WorkingDirectory", "Restart"},
+	"Install": {"WantedBy"},
+}
+
+// Verify checks a unit file without a running service manager: its
+// layout, that the executable, working directory and config file exist,
+// and, when installed, what systemd-analyze makes of it. It returns the
+// problems found.
+func Verify(p string) ([]string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var problems []string
+	values := map[string]map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.Trim(line, "[]")
+			values[section] = map[string]string{}
+		case section == "":
+			problems = append(problems, fmt.Sprintf("line %d: setting outside of a section", n))
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				problems = append(problems, fmt.Sprintf("line %d: expected key=value", n))
+				continue
+			}
+			values[section][strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{"Unit", "Service", "Install"} {
+		if values[name] == nil {
+			problems = append(problems, fmt.Sprintf("missing [%s] section", name))
+			continue
+		}
+		for _, key := range required[name] {
+			if values[name][key] == "" {
+				problems = append(problems, fmt.Sprintf("[%s] has no %s", name, key))
+			}
+		}
+	}
+
+	service := values["Service"]
+	if dir := strings.ReplaceAll(service["WorkingDirectory"], "%%", "%"); dir != "" {
+		if !filepath.IsAbs(dir) {
+			problems = append(problems, fmt.Sprintf("working directory %s is not an absolute path", dir))
+		} else if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("working directory %s does not exist", dir))
+		}
+	}
+	if command := service["ExecStart"]; command != "" {
+		problems = append(problems, verifyCommand(command)...)
+	}
+
+	return append(problems, analyze(p)...), nil
+}
+
+func verifyCommand(line string) []string {
+	args, err := split(line)
+	if err != nil {
+		return []string{fmt.Sprintf("ExecStart: %v", err)}
+	}
+
+	var problems []string
+	exe := args[0]
+	if !filepath.IsAbs(exe) {
+		problems = append(problems, fmt.Sprintf("ExecStart %s is not an absolute path", exe))
+	} else if info, err := os.Stat(exe); err != nil {
+		problems = append(problems, fmt.Sprintf("executable %s does not exist", exe))
+	} else if info.Mode()&0111 == 0 {
+		problems = append(problems, fmt.Sprintf("%s is not executable", exe))
+	}
+
+	for i := 1; i < len(args)-1; i++ {
+		if args[i] == "--config" {
+			if _, err := os.Stat(args[i+1]); err != nil {
+				problems = append(problems, fmt.Sprintf("config file %s does not exist", args[i+1]))
+			}
+		}
+	}
+	return problems
+}
+
+// split undoes quote, returning the arguments of a command line
+func split(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		quoted  bool
+		started bool
+	)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			i++
+			current.WriteByte(line[i])
+			started = true
+		case c == '"':
+			quoted = !quoted
+			started = true
+		case (c == ' ' || c == '\t') && !quoted:
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		case (c == '%' || c == '$') && i+1 < len(line) && line[i+1] == c:
+			i++
+			current.WriteByte(c)
+			started = true
+		default:
+			current.WriteByte(c)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if started {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
+
+// analyze runs systemd-analyze verify when it is available
+func analyze(p string) []string {
+	tool, err := exec.LookPath("systemd-analyze")
+	if err != nil {
+		return nil
+	}
+	out, err := exec.Command(tool, "verify", p).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	var problems []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			problems = append(problems, "systemd-analyze: "+line)
+		}
+	}
+	if len(problems) == 0 {
+		problems = append(problems, fmt.Sprintf("systemd-analyze: %v", err))
+	}
+	return problems
+}
@@ -0,0 +1,294 @@
+package templating
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	IncludesDir = "_includes"
+	DataDir     = "_data"
+)
+
+// PageData is the dot value of every processed page
+type PageData struct {
+	Data    map[string]interface{}
+	Request RequestData
+	Page    PageInfo
+}
+
+type RequestData struct {
+	Method string
+	Host   string
+	Path   string
+	Query  url.Values
+}
+
+type PageInfo struct {
+	File     string
+	Modified time.Time
+}
+
+// Engine processes the .html files of a site folder with html/template
+type Engine struct {
+	Root     string
+	LogError func(string)
+}
+
+func New(root string) *Engine {
+	return &Engine{Root: root}
+}
+
+var funcs = template.FuncMap{
+	"now":   time.Now,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"default": func(def, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+}
+
+func (e *Engine) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := path.Clean("/" + r.URL.Path)
+
+		// Partials and data are inputs, not content
+		if isPrivate(p) {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		file := e.Resolve(r.URL.Path)
+		if file == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		page, err := e.Render(file, r)
+		if err != nil {
+			e.logError(fmt.Sprintf("Template error in %s: %v", file, err))
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusInternalServerError)
+			errorPage.Execute(w, map[string]string{
+				"File":  file,
+				"Error": err.Error(),
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+}
+
+// Resolve maps a URL path to the HTML file (slash separated, relative to
+// Root) that should be processed for it, or "" when none applies
+func (e *Engine) Resolve(urlPath string) string {
+	p := path.Clean("/" + urlPath)
+	rel := strings.TrimPrefix(p, "/")
+
+	if strings.HasSuffix(urlPath, "/") || p == "/" {
+		for _, name := range []string{"index.html", "index.htm"} {
+			if index := path.Join(rel, name); e.isFile(index) {
+				return index
+			}
+		}
+		return ""
+	}
+
+	ext := strings.ToLower(path.Ext(p))
+	if (ext == ".html" || ext == ".htm") && e.isFile(rel) {
+		return rel
+	}
+	return ""
+}
+
+// Render executes a page with the site's partials and data
+func (e *Engine) Render(file string, r *http.Request) ([]byte, error) {
+	full := filepath.Join(e.Root, filepath.FromSlash(file))
+	source, err := os.ReadFile(full)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := e.partials()
+	if err != nil {
+		return nil, err
+	}
+	// The leading slash keeps the page name apart from partial names
+	if tmpl, err = tmpl.New("/" + file).Parse(string(source)); err != nil {
+		return nil, err
+	}
+
+	data, err := e.data()
+	if err != nil {
+		return nil, err
+	}
+
+	pageData := PageData{
+		Data: data,
+		Request: RequestData{
+			Method: r.Method,
+			Host:   r.Host,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+		},
+		Page: PageInfo{
+			File:     file,
+			Modified: info.ModTime(),
+		},
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&out, "/"+file, pageData); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// partials parses everything under _includes, named by relative path
+// (e.g. {{template "header.html" .}})
+func (e *Engine) partials() (*template.Template, error) {
+	root := template.New("").Funcs(funcs)
+	dir := filepath.Join(e.Root, IncludesDir)
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if _, err := root.New(filepath.ToSlash(rel)).Parse(string(content)); err != nil {
+			return fmt.Errorf("%s/%s: %v", IncludesDir, filepath.ToSlash(rel), err)
+		}
+		return nil
+	})
+
+	return root, err
+}
+
+// data loads _data/*.json and *.yaml files keyed by file name without extension
+func (e *Engine) data() (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+
+	entries, err := os.ReadDir(filepath.Join(e.Root, DataDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		if ext != ".json" && ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(e.Root, DataDir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		var v interface{}
+		if ext == ".json" {
+			err = json.Unmarshal(content, &v)
+		} else {
+			err = yaml.Unmarshal(content, &v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %v", DataDir, name, err)
+		}
+
+		data[strings.TrimSuffix(name, filepath.Ext(name))] = v
+	}
+
+	return data, nil
+}
+
+func (e *Engine) isFile(rel string) bool {
+	info, err := os.Stat(filepath.Join(e.Root, filepath.FromSlash(rel)))
+	return err == nil && !info.IsDir()
+}
+
+func (e *Engine) logError(msg string) {
+	if e.LogError != nil {
+		e.LogError(msg)
+	}
+}
+
+func isPrivate(p string) bool {
+	for _, dir := range []string{IncludesDir, DataDir} {
+		if p == "/"+dir || strings.HasPrefix(p, "/"+dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <title>Template error</title>
+    <style>
+        body { font-family: Arial, sans-serif; margin: 40px; color: #24292f; }
+        .box { border-left: 4px solid #cf222e; background: #fff5f5; padding: 16px 24px; }
+        pre { white-space: pre-wrap; font-size: 1.05em; }
+    </style>
+</head>
+<body>
+    <div class="box">
+        <h1>Template error</h1>
+        <p>Processing <strong>{{.File}}</strong> failed:</p>
+        <pre>{{.Error}}</pre>
+        <p>Partials are loaded from <code>_includes/</code> and data from <code>_data/</code>.
+        The error has also been written to the site's error log.</p>
+    </div>
+</body>
+</html>`))
@@ -0,0 +1,364 @@
+package trash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"shinobi-webserver/internal/bundle"
+	"shinobi-webserver/internal/config"
+)
+
+const (
+	itemFile   = "item.json"
+	contentDir = "content"
+)
+
+// Item is a deleted site that can still be restored
+type Item struct {
+	ID        string      `json:"-"`
+	Site      config.Site `json:"site"`
+	Workspace string      `json:"workspace"`
+	// Where the folder was, so it can be put back
+	Folder  string    `json:"folder"`
+	Deleted time.Time `json:"deleted"`
+	Size    int64     `json:"size"`
+}
+
+// Expires is when Purge removes the item
+func (it Item) Expires(retention time.Duration) time.Time {
+	return it.Deleted.Add(retention)
+}
+
+// Dir holds deleted sites, one folder each
+func Dir() string {
+	return filepath.Join(config.Dir(), "trash")
+}
+
+// Risks explains why deleting folder needs an explicit confirmation: it is
+// outside the managed sites folders, or under version control. roots are
+// the folders sites are normally created in.
+func Risks(folder string, roots []string) []string {
+	var risks []string
+
+	inside := false
+	for _, root := range roots {
+		if within(folder, root) {
+			inside = true
+			break
+		}
+	}
+	if !inside {
+		risks = append(risks, fmt.Sprintf("%s is not inside a managed sites folder", folder))
+	}
+
+	for _, vcs := range []string{".git", ".hg", ".svn"} {
+		if _, err := os.Stat(filepath.Join(folder, vcs)); err == nil {
+			risks = append(risks, fmt.Sprintf("%s is a %s repository", folder, strings.TrimPrefix(vcs, ".")))
+		}
+	}
+	return risks
+}
+
+// Protected reports why folder must never be deleted, or "" if it may be
+func Protected(folder string) string {
+	abs, err := filepath.Abs(folder)
+	if err != nil {
+		return err.Error()
+	}
+	if filepath.Dir(abs) == abs {
+		return fmt.Sprintf("%s is the root of a drive", abs)
+	}
+	if home, err := os.UserHomeDir(); err == nil && within(home, abs) {
+		return fmt.Sprintf("%s contains your home folder", abs)
+	}
+	if within(config.Dir(), abs) {
+		return fmt.Sprintf("%s contains the app's settings", abs)
+	}
+	return ""
+}
+
+// Move puts the site's folder into the trash. The site itself is left in
+// the config for the caller to remove.
+func Move(site config.Site, workspace string) (*Item, error) {
+	folder := site.FolderPath()
+	if reason := Protected(folder); reason != "" {
+		return nil, fmt.Errorf("refusing to delete: %s", reason)
+	}
+	info, err := os.Stat(folder)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a folder", folder)
+	}
+
+	now := time.Now()
+	id := fmt.Sprintf("%s-%s", now.Format("20060102-150405"), bundle.Slug(site.Name))
+	dir := filepath.Join(Dir(), id)
+	for i := 2; exists(dir); i++ {
+		dir = filepath.Join(Dir(), fmt.Sprintf("%s-%d", id, i))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	site.LastStarted = time.Time{}
+	it := &Item{
+		ID:        filepath.Base(dir),
+		Site:      site,
+		Workspace: workspace,
+		Folder:    folder,
+		Deleted:   now.UTC().Truncate(time.Second),
+		Size:      size(folder),
+	}
+	if err := writeItem(dir, it); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := move(folder, filepath.Join(dir, contentDir)); errors.Is(err, ErrLeftovers) {
+		// The trash holds a full copy, keep it
+		return it, fmt.Errorf("moved %s to the trash, but %w", folder, err)
+	} else if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to move %s to the trash: %v", folder, err)
+	}
+	return it, nil
+}
+
+// List returns the items in the trash, newest first
+func List() ([]Item, error) {
+	entries, err := os.ReadDir(Dir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		it, err := readItem(e.Name())
+		if err != nil {
+			// Not ours, or half written; leave it alone
+			continue
+		}
+		items = append(items, *it)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Deleted.After(items[j].Deleted)
+	})
+	return items, nil
+}
+
+// Restore moves the folder back and registers the site again in its
+// workspace, or the current one if that no longer exists. A new port is
+// picked if the old one was taken meanwhile.
+func Restore(cfg *config.Config, id string) (*config.Site, error) {
+	it, err := readItem(id)
+	if err != nil {
+		return nil, err
+	}
+	if exists(it.Folder) {
+		return nil, fmt.Errorf("%s already exists; move it away to restore '%s'", it.Folder, it.Site.Name)
+	}
+
+	workspace := it.Workspace
+	if !contains(cfg.WorkspaceNames(), workspace) {
+		workspace = cfg.Workspace().Name
+	}
+
+	site := it.Site
+	site.Folder = it.Folder
+	if !cfg.IsPortAvailable(site.Port) {
+		port, err := cfg.GetAvailablePort()
+		if err != nil {
+			return nil, err
+		}
+		site.Port = port
+	}
+
+	if err := os.MkdirAll(filepath.Dir(it.Folder), 0755); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(Dir(), id)
+	// Leftovers in the trash go with the item below
+	if err := move(filepath.Join(dir, contentDir), it.Folder); err != nil && !errors.Is(err, ErrLeftovers) {
+		return nil, err
+	}
+	siteID, err := cfg.RegisterSiteIn(workspace, site)
+	if err != nil {
+		// Keep the item restorable
+		move(it.Folder, filepath.Join(dir, contentDir))
+		return nil, err
+	}
+	site.ID = siteID
+	os.RemoveAll(dir)
+	return &site, nil
+}
+
+// Remove deletes an item for good
+func Remove(id string) error {
+	if _, err := readItem(id); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(Dir(), id))
+}
+
+// Purge deletes items older than retention and returns how many went
+func Purge(retention time.Duration) (int, error) {
+	items, err := List()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	now := time.Now()
+	for _, it := range items {
+		if now.Before(it.Expires(retention)) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(Dir(), it.ID)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func readItem(id string) (*Item, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("invalid trash item %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(Dir(), id, itemFile))
+	if err != nil {
+		return nil, err
+	}
+	var it Item
+	if err := json.Unmarshal(data, &it); err != nil {
+		return nil, fmt.Errorf("%s: %v", id, err)
+	}
+	it.ID = id
+	return &it, nil
+}
+
+func writeItem(dir string, it *Item) error {
+	data, err := json.MarshalIndent(it, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, itemFile), data, 0644)
+}
+
+// ErrLeftovers means a folder was copied in full but not all of the
+// originals could be removed afterwards
+var ErrLeftovers = errors.New("some originals couldn't be removed")
+
+// move renames src to dst, copying when they are on different drives.
+// Once the copy is complete it is kept, even if removing src fails.
+func move(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyDir(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	if err := os.RemoveAll(src); err != nil {
+		return fmt.Errorf("%w: %v", ErrLeftovers, err)
+	}
+	return nil
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+func size(dir string) int64 {
+	var n int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				n += info.Size()
+			}
+		}
+		return nil
+	})
+	return n
+}
+
+// within reports whether p is root or inside it
+func within(p, root string) bool {
+	p, err1 := filepath.Abs(p)
+	root, err2 := filepath.Abs(root)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func exists(p string) bool {
+	_, err := os.Lstat(p)
+	return err == nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
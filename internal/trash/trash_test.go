@@ -0,0 +1,148 @@
+package trash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shinobi-webserver/internal/config"
+)
+
+// setup points the config at a temporary folder and registers one site
+// with a file in its folder
+func setup(t *testing.T) (*config.Config, config.Site) {
+	t.Helper()
+	dir := t.TempDir()
+	config.SetPath(filepath.Join(dir, "config.json"))
+	t.Cleanup(func() { config.SetPath("") })
+
+	cfg := config.NewDefault()
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	folder := filepath.Join(dir, "sites", "docs")
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder, "index.html"), []byte("<h1>docs</h1>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	id, err := cfg.RegisterSite(config.Site{Name: "Docs", Port: 8123, Folder: folder})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg, *cfg.GetSite(id)
+}
+
+func TestMoveAndRestore(t *testing.T) {
+	cfg, site := setup(t)
+	folder := site.FolderPath()
+
+	it, err := Move(site, cfg.Workspace().Name)
+	if err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if _, err := os.Stat(folder); !os.IsNotExist(err) {
+		t.Fatalf("%s still exists after Move", folder)
+	}
+	if err := cfg.RemoveSite(site.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := List()
+	if err != nil || len(items) != 1 || items[0].ID != it.ID {
+		t.Fatalf("List = %v, %v; want the moved item", items, err)
+	}
+
+	restored, err := Restore(cfg, it.ID)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if restored.ID != site.ID {
+		t.Errorf("restored ID = %q, want %q", restored.ID, site.ID)
+	}
+	data, err := os.ReadFile(filepath.Join(folder, "index.html"))
+	if err != nil || string(data) != "<h1>docs</h1>" {
+		t.Errorf("restored index.html = %q, %v", data, err)
+	}
+	if items, _ := List(); len(items) != 0 {
+		t.Errorf("trash still has %d items after Restore", len(items))
+	}
+}
+
+func TestRestoreKeepsItem(t *testing.T) {
+	tests := []struct {
+		name   string
+		before func(t *testing.T, cfg *config.Config, site config.Site)
+	}{
+		{"folder exists again", func(t *testing.T, cfg *config.Config, site config.Site) {
+			if err := os.MkdirAll(site.FolderPath(), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"name taken", func(t *testing.T, cfg *config.Config, site config.Site) {
+			if _, err := cfg.RegisterSite(config.Site{Name: site.Name, Port: 8124, Folder: t.TempDir()}); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, site := setup(t)
+			it, err := Move(site, cfg.Workspace().Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.RemoveSite(site.ID); err != nil {
+				t.Fatal(err)
+			}
+			tt.before(t, cfg, site)
+
+			if _, err := Restore(cfg, it.ID); err == nil {
+				t.Fatal("Restore succeeded, want an error")
+			}
+			if _, err := readItem(it.ID); err != nil {
+				t.Errorf("item gone after failed Restore: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(Dir(), it.ID, contentDir, "index.html")); err != nil {
+				t.Errorf("trashed files gone after failed Restore: %v", err)
+			}
+		})
+	}
+}
+
+func TestMoveRefusesProtected(t *testing.T) {
+	cfg, site := setup(t)
+	site.Folder = string(filepath.Separator)
+	if _, err := Move(site, cfg.Workspace().Name); err == nil {
+		t.Fatal("Move of the root folder succeeded")
+	}
+}
+
+func TestMoveKeepsCopyWhenOriginalsStay(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions don't stop root from removing files")
+	}
+	src := filepath.Join(t.TempDir(), "site")
+	locked := filepath.Join(src, "locked")
+	if err := os.MkdirAll(locked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(locked, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(locked, 0555)
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+
+	// A missing parent makes the rename fail, so move copies instead
+	dst := filepath.Join(t.TempDir(), "missing", "content")
+	err := move(src, dst)
+	if !errors.Is(err, ErrLeftovers) {
+		t.Fatalf("move = %v, want ErrLeftovers", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "locked", "a.txt")); err != nil {
+		t.Errorf("copy removed: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package tray
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+
+	"shinobi-webserver/assets"
+)
+
+// State is what the tray icon shows
+type State int
+
+const (
+	// Idle means no site is running
+	Idle State = iota
+	// Running means at least one site is running
+	Running
+	// Error means a site stopped when it should be running
+	Error
+)
+
+// iconSize is large enough for HiDPI trays; the desktop scales it down
+const iconSize = 64
+
+var (
+	iconsOnce sync.Once
+	icons     map[State]fyne.Resource
+)
+
+// Icon returns the app icon with a badge for the state
+func Icon(state State) fyne.Resource {
+	iconsOnce.Do(func() {
+		icons = map[State]fyne.Resource{Idle: theme.FyneLogo(), Running: theme.FyneLogo(), Error: theme.FyneLogo()}
+
+		src, err := png.Decode(bytes.NewReader(assets.Icon.Content()))
+		if err != nil {
+			return
+		}
+		base := scale(src, iconSize)
+		icons[Idle] = encode("tray.png", base, nil)
+		icons[Running] = encode("tray-running.png", base, color.NRGBA{R: 0x2e, G: 0xb8, B: 0x4b, A: 0xff})
+		icons[Error] = encode("tray-error.png", base, color.NRGBA{R: 0xe0, G: 0x32, B: 0x32, A: 0xff})
+	})
+	return icons[state]
+}
+
+// scale shrinks img to a size x size square by averaging blocks of pixels
+func scale(img image.Image, size int) *image.NRGBA {
+	b := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/size, b.Min.Y+(y+1)*b.Dy()/size
+		for x := 0; x < size; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/size, b.Min.X+(x+1)*b.Dx()/size
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			if n == 0 || a == 0 {
+				continue
+			}
+			// Average premultiplied values, then undo the premultiplication
+			out.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r * 0xff / a),
+				G: uint8(g * 0xff / a),
+				B: uint8(bl * 0xff / a),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return out
+}
+
+// encode draws a badge of the given colour in the bottom right corner,
+// if any, and returns the result as a PNG resource
+func encode(name string, base *image.NRGBA, badge color.Color) fyne.Resource {
+	img := image.NewNRGBA(base.Bounds())
+	draw.Draw(img, img.Bounds(), base, image.Point{}, draw.Src)
+
+	if badge != nil {
+		radius := iconSize / 5
+		cx, cy := iconSize-radius-1, iconSize-radius-1
+		ring := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+		for y := cy - radius; y <= cy+radius; y++ {
+			for x := cx - radius; x <= cx+radius; x++ {
+				d := (x-cx)*(x-cx) + (y-cy)*(y-cy)
+				switch {
+				case d <= (radius-2)*(radius-2):
+					img.Set(x, y, badge)
+				case d <= radius*radius:
+					img.Set(x, y, ring)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return theme.FyneLogo()
+	}
+	return fyne.NewStaticResource(name, buf.Bytes())
+}
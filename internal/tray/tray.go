@@ -1,32 +1,168 @@
 package tray
 
 import (
+	"fmt"
+	"strings"
+	"sync"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/driver/desktop"
-	"fyne.io/fyne/v2/theme"
 )
 
+// Site is what the tray shows about one site
+type Site struct {
+	ID      string
+	Name    string
+	Port    int
+	Running bool
+	// Failed is set when the site should be running but its server
+	// stopped on its own
+	Failed bool
+}
+
+// Actions are run from the tray menu
+type Actions struct {
+	Show     func()
+	Start    func(id string)
+	Stop     func(id string)
+	Open     func(id string)
+	CopyURL  func(id string)
+	StartAll func()
+	StopAll  func()
+}
+
 type Tray struct {
-	menu *fyne.Menu
+	app     fyne.App
+	desk    desktop.App
+	actions Actions
+	menu    *fyne.Menu
+	// Whether the desktop shows a tray icon at all
+	available bool
+
+	mu sync.Mutex
+	// What the menu was last built from, to skip identical rebuilds
+	shown string
+}
+
+func NewTray(app fyne.App, actions Actions) *Tray {
+	desk, ok := app.(desktop.App)
+	t := &Tray{
+		app:       app,
+		desk:      desk,
+		actions:   actions,
+		available: ok,
+	}
+	t.Update(nil)
+	return t
+}
+
+// Available reports whether the tray icon could be set up, so the window
+// can be hidden
+func (t *Tray) Available() bool {
+	return t.available
+}
+
+// Update rebuilds the menu and icon for the given sites. It does nothing
+// when they look the same as last time, so it can be called on every
+// refresh.
+func (t *Tray) Update(sites []Site) {
+	if !t.available {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := signature(sites)
+	if t.menu != nil && key == t.shown {
+		return
+	}
+	t.shown = key
+
+	t.menu = t.buildMenu(sites)
+	t.desk.SetSystemTrayMenu(t.menu)
+	t.desk.SetSystemTrayIcon(Icon(stateOf(sites)))
 }
 
-func NewTray(app fyne.App, onShow func()) *Tray {
-	// Create tray menu
-	menu := fyne.NewMenu("Shinobi Web Server",
-		fyne.NewMenuItem("Show", onShow),
+func (t *Tray) buildMenu(sites []Site) *fyne.Menu {
+	items := []*fyne.MenuItem{
+		fyne.NewMenuItem("Show", t.actions.Show),
 		fyne.NewMenuItemSeparator(),
-		fyne.NewMenuItem("Quit", app.Quit),
+	}
+
+	if len(sites) == 0 {
+		empty := fyne.NewMenuItem("No sites", nil)
+		empty.Disabled = true
+		items = append(items, empty)
+	}
+	for _, site := range sites {
+		items = append(items, t.siteItem(site))
+	}
+
+	running := 0
+	for _, site := range sites {
+		if site.Running {
+			running++
+		}
+	}
+	startAll := fyne.NewMenuItem("Start All", t.actions.StartAll)
+	startAll.Disabled = running == len(sites)
+	stopAll := fyne.NewMenuItem("Stop All", t.actions.StopAll)
+	stopAll.Disabled = running == 0
+
+	items = append(items,
+		fyne.NewMenuItemSeparator(),
+		startAll,
+		stopAll,
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Quit", t.app.Quit),
 	)
+	return fyne.NewMenu("Shinobi Web Server", items...)
+}
+
+func (t *Tray) siteItem(site Site) *fyne.MenuItem {
+	id := site.ID
+	start := fyne.NewMenuItem("Start", func() { t.actions.Start(id) })
+	stop := fyne.NewMenuItem("Stop", func() { t.actions.Stop(id) })
+	open := fyne.NewMenuItem("Open in Browser", func() { t.actions.Open(id) })
+	copyURL := fyne.NewMenuItem("Copy URL", func() { t.actions.CopyURL(id) })
+
+	start.Disabled = site.Running
+	stop.Disabled = !site.Running
+	open.Disabled = !site.Running
+
+	item := fyne.NewMenuItem(label(site), nil)
+	item.ChildMenu = fyne.NewMenu("", start, stop, fyne.NewMenuItemSeparator(), open, copyURL)
+	return item
+}
 
-	// Set system tray if supported
-	if desk, ok := app.(desktop.App); ok {
-		desk.SetSystemTrayMenu(menu)
+func label(site Site) string {
+	status := "○"
+	switch {
+	case site.Failed:
+		status = "⚠"
+	case site.Running:
+		status = "●"
+	}
+	return fmt.Sprintf("%s %s  :%d", status, site.Name, site.Port)
+}
 
-		// Use theme icon as fallback
-		desk.SetSystemTrayIcon(theme.FyneLogo())
+func stateOf(sites []Site) State {
+	state := Idle
+	for _, site := range sites {
+		if site.Failed {
+			return Error
+		}
+		if site.Running {
+			state = Running
+		}
 	}
+	return state
+}
 
-	return &Tray{
-		menu: menu,
+func signature(sites []Site) string {
+	var b strings.Builder
+	for _, site := range sites {
+		fmt.Fprintf(&b, "%s|%s|%d|%t|%t\n", site.ID, site.Name, site.Port, site.Running, site.Failed)
 	}
+	return b.String()
 }
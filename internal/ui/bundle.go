@@ -0,0 +1,292 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+
+	"shinobi-webserver/internal/bundle"
+	"shinobi-webserver/internal/config"
+)
+
+// exportSites saves the sites with the given IDs, or the whole workspace
+// when ids is empty, as a bundle
+func (u *UI) exportSites(ids []string, withContent bool) {
+	var sites []config.Site
+	if len(ids) == 0 {
+		sites = u.config.Sites()
+	}
+	for _, id := range ids {
+		if site := u.config.GetSite(id); site != nil {
+			sites = append(sites, *site)
+		}
+	}
+	if len(sites) == 0 {
+		dialog.ShowInformation("Export Sites", "There are no sites to export.", u.window)
+		return
+	}
+
+	fileName := u.config.Workspace().Name + "-sites"
+	if len(ids) == 1 {
+		fileName = sites[0].Name
+	}
+
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		target := writer.URI().Path()
+		writer.Close()
+
+		u.updateStatus(fmt.Sprintf("Exporting %d sites...", len(sites)))
+		go func() {
+			if err := bundle.Write(bundle.New(sites), target, withContent); err != nil {
+				dialog.ShowError(fmt.Errorf("export failed: %v", err), u.window)
+				return
+			}
+			u.updateStatus(fmt.Sprintf("Exported %d sites to %s", len(sites), target))
+		}()
+	}, u.window)
+
+	if withContent {
+		save.SetFileName(fileName + ".zip")
+		save.SetFilter(storage.NewExtensionFileFilter([]string{".zip"}))
+	} else {
+		save.SetFileName(fileName + ".json")
+		save.SetFilter(storage.NewExtensionFileFilter([]string{".json", ".yaml", ".yml"}))
+	}
+	save.Show()
+}
+
+func (u *UI) importSites() {
+	open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		source := reader.URI().Path()
+		reader.Close()
+
+		b, err := bundle.Read(source)
+		if err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		u.confirmImport(b)
+	}, u.window)
+	open.SetFilter(storage.NewExtensionFileFilter([]string{".json", ".yaml", ".yml", ".zip"}))
+	open.Show()
+}
+
+// confirmImport lists the bundled sites and asks how to handle names that
+// are taken
+func (u *UI) confirmImport(b *bundle.Bundle) {
+	var lines []string
+	conflicts := 0
+	for _, e := range b.Sites {
+		line := fmt.Sprintf("• %s (port %d)", e.Name, e.Port)
+		if u.config.FindSite(e.Name) != nil {
+			line += " - name already used"
+			conflicts++
+		}
+		lines = append(lines, line)
+	}
+
+	summary := fmt.Sprintf("Import %d sites into workspace '%s'", len(b.Sites), u.config.Workspace().Name)
+	if b.HasContent() {
+		summary += ", with their files"
+	}
+	text := widget.NewLabel(summary + ":\n\n" + strings.Join(lines, "\n") + "\n\nTaken ports are replaced with free ones.")
+	text.Wrapping = fyne.TextWrapWord
+
+	choices := make([]string, len(bundle.Conflicts))
+	for i, c := range bundle.Conflicts {
+		choices[i] = string(c)
+	}
+	conflictSelect := widget.NewSelect(choices, nil)
+	conflictSelect.SetSelected(string(bundle.ConflictRename))
+
+	content := container.NewVBox(text)
+	if conflicts > 0 {
+		content.Add(widget.NewForm(widget.NewFormItem("Taken names", conflictSelect)))
+	}
+
+	dialog.ShowCustomConfirm("Import Sites", "Import", "Cancel", container.NewVScroll(content), func(ok bool) {
+		if !ok {
+			return
+		}
+
+		opts := bundle.Options{
+			OnConflict: bundle.Conflict(conflictSelect.Selected),
+			BeforeReplace: func(id string) error {
+				if err := u.autoSnapshot(id, "Before import replaced it"); err != nil {
+					return err
+				}
+				if srv := u.serverFor(id); srv != nil {
+					srv.Stop()
+					u.dropServer(id)
+				}
+				return nil
+			},
+		}
+		result := bundle.Import(u.config, b, opts)
+		u.refreshSiteList()
+		u.showImportResult(result)
+	}, u.window)
+}
+
+func (u *UI) showImportResult(result *bundle.Result) {
+	var b strings.Builder
+	for _, o := range result.Sites {
+		fmt.Fprintf(&b, "• %s: %s", o.Name, o.Action)
+		if o.OriginalName != "" {
+			fmt.Fprintf(&b, ", renamed from %s", o.OriginalName)
+		}
+		if o.OriginalPort != 0 {
+			fmt.Fprintf(&b, ", port %d was taken so %d is used", o.OriginalPort, o.Port)
+		}
+		if o.Error != "" {
+			fmt.Fprintf(&b, ": %s", o.Error)
+		}
+		b.WriteString("\n")
+	}
+
+	text := widget.NewLabel(b.String())
+	text.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(text)
+	scroll.SetMinSize(fyne.NewSize(480, 200))
+
+	dialog.ShowCustom("Import Complete", "Close", scroll, u.window)
+	u.updateStatus(fmt.Sprintf("Imported %d of %d sites", len(result.Sites)-result.Failed(), len(result.Sites)))
+}
+
+// openFolderAsSite registers an existing folder, such as build output,
+// as a site
+func (u *UI) openFolderAsSite() {
+	dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		if dir == nil {
+			return
+		}
+		u.addFolder(dir.Path())
+	}, u.window)
+}
+
+// onDropped adds a folder dropped onto the window
+func (u *UI) onDropped(_ fyne.Position, uris []fyne.URI) {
+	for _, uri := range uris {
+		if info, err := os.Stat(uri.Path()); err == nil && info.IsDir() {
+			u.addFolder(uri.Path())
+			return
+		}
+	}
+	u.updateStatus("Drop a folder to add it as a site")
+}
+
+// addFolder registers dir from its project file if it has one, otherwise
+// asks to confirm what was detected
+func (u *UI) addFolder(dir string) {
+	if bundle.HasProject(dir) {
+		outcome, err := bundle.OpenProject(u.config, dir)
+		if err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		u.refreshSiteList()
+		u.showImportResult(&bundle.Result{Sites: []bundle.Outcome{outcome}})
+		return
+	}
+
+	site, err := bundle.ProposeSite(u.config, dir)
+	if err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(site.Name)
+
+	portEntry := widget.NewEntry()
+	portEntry.SetText(strconv.Itoa(site.Port))
+
+	entryFileEntry := widget.NewSelectEntry(bundle.PageFiles(site.Folder))
+	entryFileEntry.SetText(site.EntryFile)
+	entryHint := ""
+	if site.EntryFile == "" {
+		entryHint = "No index.html or index.htm found"
+	}
+
+	logsCheck := widget.NewCheck("Keep logs outside the folder", nil)
+	logsCheck.SetChecked(true)
+
+	dialog.ShowForm("Add Existing Folder", "Add", "Cancel",
+		[]*widget.FormItem{
+			{Text: "Folder", Widget: widget.NewLabel(site.Folder)},
+			{Text: "Site Name", Widget: nameEntry},
+			{Text: "Port", Widget: portEntry},
+			{Text: "Entry File", Widget: entryFileEntry, HintText: entryHint},
+			{Text: "Logs", Widget: logsCheck},
+		},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			port, err := strconv.Atoi(portEntry.Text)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("invalid port number"), u.window)
+				return
+			}
+			if strings.TrimSpace(nameEntry.Text) == "" || strings.TrimSpace(entryFileEntry.Text) == "" {
+				dialog.ShowError(fmt.Errorf("site name and entry file are required"), u.window)
+				return
+			}
+
+			site.Name = strings.TrimSpace(nameEntry.Text)
+			site.Port = port
+			site.EntryFile = strings.TrimSpace(entryFileEntry.Text)
+			site.LogsFolder = ""
+			if logsCheck.Checked {
+				site.LogsFolder = path.Join("logs", bundle.Slug(site.Name))
+			}
+
+			if _, err := u.config.RegisterSite(*site); err != nil {
+				dialog.ShowError(err, u.window)
+				return
+			}
+			u.refreshSiteList()
+			u.updateStatus(fmt.Sprintf("Added '%s' from %s", site.Name, site.Folder))
+		}, u.window)
+}
+
+// saveProjectFile writes the site's definition into its folder
+func (u *UI) saveProjectFile(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+
+	p, err := bundle.WriteProject(*site)
+	if err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+	u.updateStatus(fmt.Sprintf("Saved %s", p))
+}
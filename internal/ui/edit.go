@@ -0,0 +1,301 @@
+package ui
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"shinobi-webserver/internal/bundle"
+	"shinobi-webserver/internal/config"
+	"shinobi-webserver/internal/editor"
+	"shinobi-webserver/internal/har"
+	"shinobi-webserver/internal/netsim"
+)
+
+const noThrottling = "No Throttling"
+
+// editSite shows every setting of a site for editing. A running server is
+// restarted when the change needs it and updated in place otherwise.
+func (u *UI) editSite(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(site.Name)
+
+	portEntry := widget.NewEntry()
+	portEntry.SetText(strconv.Itoa(site.Port))
+
+	folderEntry := widget.NewEntry()
+	folderEntry.SetText(site.FolderPath())
+	browse := widget.NewButton("Browse...", func() {
+		dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err == nil && dir != nil {
+				folderEntry.SetText(dir.Path())
+			}
+		}, u.window)
+	})
+
+	entryFileEntry := widget.NewSelectEntry(bundle.PageFiles(site.FolderPath()))
+	entryFileEntry.SetText(site.EntryFile)
+
+	markdownCheck := widget.NewCheck("Render Markdown", nil)
+	markdownCheck.SetChecked(site.Markdown)
+	layoutEntry := widget.NewEntry()
+	layoutEntry.SetPlaceHolder("Built-in layout")
+	layoutEntry.SetText(site.MarkdownLayout)
+
+	templatesCheck := widget.NewCheck("Process templates", nil)
+	templatesCheck.SetChecked(site.Templates)
+
+	profiles := []string{noThrottling}
+	for _, p := range u.config.NetworkProfiles() {
+		profiles = append(profiles, p.Name)
+	}
+	networkSelect := widget.NewSelect(profiles, nil)
+	networkSelect.SetSelected(noThrottling)
+	if site.NetworkProfile != "" {
+		networkSelect.SetSelected(site.NetworkProfile)
+	}
+
+	harEntry := widget.NewEntry()
+	harEntry.SetPlaceHolder("No HAR mock")
+	harEntry.SetText(site.HARMock)
+	harMatchBody := widget.NewCheck("Match request bodies", nil)
+	harMatchBody.SetChecked(site.HARMatchBody)
+
+	logsCheck := widget.NewCheck("Keep logs outside the folder", nil)
+	logsCheck.SetChecked(site.LogsFolder != "")
+
+	autoStartCheck := widget.NewCheck("Start when the app launches", nil)
+	autoStartCheck.SetChecked(site.AutoStart)
+	startOrderEntry := widget.NewEntry()
+	startOrderEntry.SetText(strconv.Itoa(site.StartOrder))
+
+	// Other sites of the workspace this one can wait for on launch
+	depIDs := map[string]string{}
+	var depNames []string
+	for _, s := range u.config.Sites() {
+		if s.ID != id {
+			depIDs[s.Name] = s.ID
+			depNames = append(depNames, s.Name)
+		}
+	}
+	dependsGroup := widget.NewCheckGroup(depNames, nil)
+	for _, dep := range site.DependsOn {
+		if s := u.config.GetSite(dep); s != nil && depIDs[s.Name] == dep {
+			dependsGroup.Selected = append(dependsGroup.Selected, s.Name)
+		}
+	}
+
+	form := widget.NewForm(
+		widget.NewFormItem("Site Name", nameEntry),
+		widget.NewFormItem("Port", portEntry),
+		&widget.FormItem{Text: "Folder", Widget: container.NewBorder(nil, nil, nil, browse, folderEntry), HintText: "Files are not moved"},
+		widget.NewFormItem("Entry File", entryFileEntry),
+		widget.NewFormItem("Markdown", markdownCheck),
+		widget.NewFormItem("Markdown Layout", layoutEntry),
+		widget.NewFormItem("Templates", templatesCheck),
+		widget.NewFormItem("Network", networkSelect),
+		widget.NewFormItem("HAR Mock", harEntry),
+		widget.NewFormItem("", harMatchBody),
+		widget.NewFormItem("Logs", logsCheck),
+		widget.NewFormItem("Launch", autoStartCheck),
+		&widget.FormItem{Text: "Start Order", Widget: startOrderEntry, HintText: "Lower starts first"},
+		&widget.FormItem{Text: "Depends On", Widget: dependsGroup, HintText: "Started before this site on launch"},
+	)
+
+	d := dialog.NewCustomConfirm("Edit "+name, "Save", "Cancel", container.NewVScroll(form), func(ok bool) {
+		if !ok {
+			return
+		}
+
+		port, err := strconv.Atoi(strings.TrimSpace(portEntry.Text))
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("invalid port number"), u.window)
+			return
+		}
+		if strings.TrimSpace(entryFileEntry.Text) == "" {
+			dialog.ShowError(fmt.Errorf("entry file is required"), u.window)
+			return
+		}
+		startOrder, err := strconv.Atoi(strings.TrimSpace(startOrderEntry.Text))
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("invalid start order"), u.window)
+			return
+		}
+
+		// Start from the site as it is now, so changes made while the
+		// dialog was open, like starting or stopping it, are kept
+		current := u.config.GetSite(id)
+		if current == nil {
+			dialog.ShowError(fmt.Errorf("site '%s' was removed", name), u.window)
+			return
+		}
+		original := *current
+		updated := original
+		updated.Name = strings.TrimSpace(nameEntry.Text)
+		updated.Port = port
+		updated.Folder = strings.TrimSpace(folderEntry.Text)
+		updated.EntryFile = strings.TrimSpace(entryFileEntry.Text)
+		updated.Markdown = markdownCheck.Checked
+		updated.MarkdownLayout = strings.TrimSpace(layoutEntry.Text)
+		updated.Templates = templatesCheck.Checked
+		updated.NetworkProfile = ""
+		if networkSelect.Selected != noThrottling {
+			updated.NetworkProfile = networkSelect.Selected
+		}
+		updated.AutoStart = autoStartCheck.Checked
+		updated.StartOrder = startOrder
+		// Keep dependencies on sites of other workspaces
+		updated.DependsOn = nil
+		for _, dep := range original.DependsOn {
+			if s := u.config.GetSite(dep); s == nil || depIDs[s.Name] != dep {
+				updated.DependsOn = append(updated.DependsOn, dep)
+			}
+		}
+		for _, name := range dependsGroup.Selected {
+			updated.DependsOn = append(updated.DependsOn, depIDs[name])
+		}
+		updated.HARMock = strings.TrimSpace(harEntry.Text)
+		updated.HARMatchBody = updated.HARMock != "" && harMatchBody.Checked
+		switch {
+		case !logsCheck.Checked:
+			updated.LogsFolder = ""
+		case updated.LogsFolder == "" || updated.Name != original.Name:
+			updated.LogsFolder = path.Join("logs", bundle.Slug(updated.Name))
+		}
+
+		if updated.HARMock != "" && updated.HARMock != original.HARMock {
+			if _, err := har.Load(updated.HARMock); err != nil {
+				dialog.ShowError(fmt.Errorf("failed to load HAR: %v", err), u.window)
+				return
+			}
+		}
+		u.saveSite(id, original, updated)
+	}, u.window)
+	d.Resize(fyne.NewSize(560, 560))
+	d.Show()
+}
+
+// saveSite stores the edited site and brings its server in line
+func (u *UI) saveSite(id string, original, updated config.Site) {
+	if err := u.config.UpdateSite(id, updated); err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	name := updated.Name
+	site := u.config.GetSite(id)
+	srv := u.serverFor(id)
+	if site == nil || srv == nil {
+		u.refreshSiteList()
+		u.updateStatus(fmt.Sprintf("Site '%s' saved", name))
+		return
+	}
+
+	restart := original.Port != site.Port ||
+		original.FolderPath() != site.FolderPath() ||
+		original.EntryFile != site.EntryFile ||
+		original.HARMock != site.HARMock ||
+		original.HARMatchBody != site.HARMatchBody ||
+		original.LogsPath() != site.LogsPath()
+
+	if restart {
+		u.restartSite(id)
+	} else {
+		srv.SetMarkdown(site.Markdown, site.MarkdownLayout)
+		srv.SetTemplates(site.Templates)
+		srv.SetNetworkProfile(netsim.Find(site.NetworkProfile, u.config.Settings().NetworkProfiles))
+	}
+
+	u.refreshSiteList()
+	u.updateStatus(fmt.Sprintf("Site '%s' saved", name))
+}
+
+// openSiteFolder opens the site's folder in the file manager
+func (u *UI) openSiteFolder(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+
+	if err := editor.OpenFolder(site.FolderPath()); err != nil {
+		dialog.ShowError(fmt.Errorf("failed to open folder: %v", err), u.window)
+		return
+	}
+
+	u.updateStatus(fmt.Sprintf("Opened folder for editing: %s", site.FolderPath()))
+}
+
+// duplicateSite copies a site's content and settings to a new site on a
+// free port
+func (u *UI) duplicateSite(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+
+	used := map[string]bool{}
+	for _, s := range u.config.Sites() {
+		used[strings.ToLower(s.Name)] = true
+	}
+	newName := name + "-copy"
+	for i := 2; used[strings.ToLower(newName)]; i++ {
+		newName = fmt.Sprintf("%s-copy-%d", name, i)
+	}
+
+	port, err := u.config.GetAvailablePort()
+	if err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	sitesDir := u.config.Workspace().SitesDir()
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(newName)
+	portEntry := widget.NewEntry()
+	portEntry.SetText(strconv.Itoa(port))
+	folderEntry := widget.NewEntry()
+	folderEntry.SetText(bundle.FreeFolder(filepath.Join(sitesDir, bundle.Slug(newName))))
+	nameEntry.OnChanged = func(text string) {
+		if text != "" {
+			folderEntry.SetText(bundle.FreeFolder(filepath.Join(sitesDir, bundle.Slug(text))))
+		}
+	}
+
+	dialog.ShowForm("Duplicate "+name, "Duplicate", "Cancel",
+		[]*widget.FormItem{
+			{Text: "Site Name", Widget: nameEntry},
+			{Text: "Port", Widget: portEntry},
+			{Text: "Folder", Widget: folderEntry},
+		},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			port, err := strconv.Atoi(strings.TrimSpace(portEntry.Text))
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("invalid port number"), u.window)
+				return
+			}
+			copied, err := bundle.Duplicate(u.config, id, strings.TrimSpace(nameEntry.Text), strings.TrimSpace(folderEntry.Text), port)
+			if err != nil {
+				dialog.ShowError(err, u.window)
+				return
+			}
+			u.refreshSiteList()
+			u.updateStatus(fmt.Sprintf("Duplicated '%s' as '%s' on port %d", name, copied.Name, copied.Port))
+		}, u.window)
+}
@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"fmt"
+
+	"shinobi-webserver/internal/instance"
+)
+
+// handleRequest carries out what a later launch asked for and brings the
+// window to the front. Requests arrive on the listener's goroutine, so
+// they go through serialize like other background work.
+func (u *UI) handleRequest(action string, args []string) (err error) {
+	u.serialize(func() {
+		err = u.runRequest(action, args)
+	})
+	return err
+}
+
+func (u *UI) runRequest(action string, args []string) error {
+	u.window.Show()
+	u.window.RequestFocus()
+
+	switch action {
+	case instance.ActionShow:
+		return nil
+	case instance.ActionOpen:
+		if len(args) != 1 {
+			return fmt.Errorf("open: expected a folder")
+		}
+		u.addFolder(args[0])
+		return nil
+	case instance.ActionStart, instance.ActionStop:
+		if len(args) != 1 {
+			return fmt.Errorf("%s: expected a site", action)
+		}
+		site := u.config.GetSite(args[0])
+		if site == nil {
+			site = u.config.FindSite(args[0])
+		}
+		if site == nil {
+			return fmt.Errorf("no site with ID or name %q", args[0])
+		}
+		if action == instance.ActionStart {
+			u.startSite(site.ID)
+		} else {
+			u.stopSite(site.ID)
+		}
+		if u.isRunning(site.ID) != (action == instance.ActionStart) {
+			return fmt.Errorf("couldn't %s '%s', see the window for details", action, site.Name)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown request %q", action)
+}
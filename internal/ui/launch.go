@@ -0,0 +1,36 @@
+package ui
+
+import (
+	"fmt"
+
+	"shinobi-webserver/internal/config"
+	"shinobi-webserver/internal/launch"
+)
+
+// launchSites starts the sites set to auto-start and those running at the
+// last exit, and sends a notification if any of them failed
+func (u *UI) launchSites() {
+	summary := launch.Run(u.config, func(site config.Site) error {
+		current := u.config.GetSite(site.ID)
+		if current == nil {
+			return fmt.Errorf("site was removed")
+		}
+		return u.startServer(current)
+	})
+
+	if len(summary.Started) == 0 && len(summary.Failed) == 0 {
+		return
+	}
+	u.refreshSiteList()
+
+	if len(summary.Failed) == 0 {
+		u.updateStatus(fmt.Sprintf("Started %d sites", len(summary.Started)))
+		return
+	}
+
+	u.updateStatus(fmt.Sprintf("Started %d sites, %d failed", len(summary.Started), len(summary.Failed)))
+	u.notifier.Notify(config.NotifyFailedStart, "",
+		fmt.Sprintf("%d sites didn't start", len(summary.Failed)),
+		summary.Message(),
+	)
+}
@@ -0,0 +1,222 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"shinobi-webserver/internal/config"
+	"shinobi-webserver/internal/notify"
+)
+
+// Crashed servers are restarted at most this often within restartWindow,
+// so one that can't stay up isn't started forever
+const (
+	maxRestarts   = 3
+	restartWindow = 10 * time.Minute
+	restartDelay  = 2 * time.Second
+)
+
+var notifyLabels = map[string]string{
+	config.NotifyCrash:       "Servers that stop on their own",
+	config.NotifyFailedStart: "Sites that fail to start",
+	config.NotifyRestart:     "Automatic restarts",
+	config.NotifyErrors:      "Lasting server error spikes",
+}
+
+func (u *UI) initNotifications() {
+	u.notifier = notify.New(
+		func() config.Notifications { return u.config.Settings().Notifications },
+		func(title, content string) {
+			u.app.SendNotification(fyne.NewNotification(title, content))
+		},
+	)
+	u.spikes = notify.NewSpikes()
+	u.restarts = make(map[string][]time.Time)
+}
+
+// serverCrashed is called from a server's goroutine when it stops on its
+// own. The site stays marked as running, which shows it in error until it
+// is restarted or stopped.
+func (u *UI) serverCrashed(id string, err error) {
+	u.serialize(func() {
+		name := u.siteName(id)
+		u.refreshSiteList()
+
+		if !u.config.Settings().RestartCrashed {
+			u.notifier.Notify(config.NotifyCrash, id,
+				fmt.Sprintf("Site '%s' stopped", name), err.Error())
+			return
+		}
+		if !u.allowRestart(id) {
+			u.notifier.Notify(config.NotifyCrash, id,
+				fmt.Sprintf("Site '%s' keeps stopping", name),
+				fmt.Sprintf("%v\nIt was restarted %d times in %v and is left stopped.", err, maxRestarts, restartWindow))
+			return
+		}
+
+		time.AfterFunc(restartDelay, func() {
+			u.serialize(func() { u.restartCrashed(id, err) })
+		})
+	})
+}
+
+// restartCrashed starts a crashed site again, unless it was stopped,
+// removed or started by hand in the meantime
+func (u *UI) restartCrashed(id string, crash error) {
+	site := u.config.GetSite(id)
+	if site == nil || !site.Running || u.isRunning(id) {
+		return
+	}
+	name := site.Name
+
+	u.dropServer(id)
+	if err := u.startServer(site); err != nil {
+		u.notifier.Notify(config.NotifyRestart, id,
+			fmt.Sprintf("Site '%s' couldn't be restarted", name),
+			fmt.Sprintf("It stopped with: %v\nRestarting failed: %v", crash, err))
+	} else {
+		u.notifier.Notify(config.NotifyRestart, id,
+			fmt.Sprintf("Site '%s' was restarted", name),
+			fmt.Sprintf("It stopped with: %v", crash))
+	}
+	u.refreshSiteList()
+}
+
+// allowRestart counts a restart of the site unless it used up its budget.
+// Only called from serialize.
+func (u *UI) allowRestart(id string) bool {
+	var recent []time.Time
+	for _, t := range u.restarts[id] {
+		if time.Since(t) < restartWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= maxRestarts {
+		u.restarts[id] = recent
+		return false
+	}
+	u.restarts[id] = append(recent, time.Now())
+	return true
+}
+
+func (u *UI) notifyFailedStart(site *config.Site, err error) {
+	u.notifier.Notify(config.NotifyFailedStart, site.ID,
+		fmt.Sprintf("Site '%s' didn't start", site.Name), err.Error())
+}
+
+// checkErrorSpikes looks at the server errors of running sites, called
+// on every refresh
+func (u *UI) checkErrorSpikes() {
+	settings := u.config.Settings().Notifications
+	now := time.Now()
+	for id, srv := range u.allServers() {
+		if !srv.IsRunning() {
+			u.spikes.Forget(id)
+			continue
+		}
+		if u.spikes.Observe(id, srv.ErrorCount(), now, settings.SpikeRate(), settings.SpikeMinutes()) {
+			u.notifier.Notify(config.NotifyErrors, id,
+				fmt.Sprintf("Site '%s' is answering with errors", u.siteName(id)),
+				fmt.Sprintf("At least %d server errors a minute for %d minutes, see %s",
+					settings.SpikeRate(), settings.SpikeMinutes(), srv.LogsDir))
+		}
+	}
+}
+
+func (u *UI) showNotificationSettings() {
+	current := u.config.Settings().Notifications
+
+	var labels []string
+	var checked []string
+	for _, kind := range config.NotifyKinds {
+		labels = append(labels, notifyLabels[kind])
+		if current.Enabled(kind) {
+			checked = append(checked, notifyLabels[kind])
+		}
+	}
+	kinds := widget.NewCheckGroup(labels, nil)
+	kinds.SetSelected(checked)
+
+	quietFrom := widget.NewEntry()
+	quietFrom.SetPlaceHolder("22:00")
+	quietFrom.SetText(current.QuietFrom)
+	quietUntil := widget.NewEntry()
+	quietUntil.SetPlaceHolder("08:00")
+	quietUntil.SetText(current.QuietUntil)
+
+	perHour := widget.NewEntry()
+	perHour.SetText(strconv.Itoa(current.PerHour()))
+	errorRate := widget.NewEntry()
+	errorRate.SetText(strconv.Itoa(current.SpikeRate()))
+	errorMinutes := widget.NewEntry()
+	errorMinutes.SetText(strconv.Itoa(current.SpikeMinutes()))
+
+	dialog.ShowForm("Notifications", "Save", "Cancel",
+		[]*widget.FormItem{
+			{Text: "Notify About", Widget: kinds},
+			{Text: "Quiet From", Widget: quietFrom, HintText: "HH:MM, empty for none"},
+			{Text: "Quiet Until", Widget: quietUntil},
+			{Text: "At Most per Hour", Widget: perHour},
+			{Text: "Errors per Minute", Widget: errorRate, HintText: "Server errors that count as a spike"},
+			{Text: "For Minutes", Widget: errorMinutes, HintText: "How long a spike lasts before notifying"},
+		},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+
+			from, until := strings.TrimSpace(quietFrom.Text), strings.TrimSpace(quietUntil.Text)
+			if (from == "") != (until == "") {
+				dialog.ShowError(fmt.Errorf("quiet hours need a start and an end"), u.window)
+				return
+			}
+			for _, clock := range []string{from, until} {
+				if _, err := config.ParseClock(clock); clock != "" && err != nil {
+					dialog.ShowError(err, u.window)
+					return
+				}
+			}
+
+			numbers := make([]int, 3)
+			for i, entry := range []*widget.Entry{perHour, errorRate, errorMinutes} {
+				n, err := strconv.Atoi(strings.TrimSpace(entry.Text))
+				if err != nil || n < 1 {
+					dialog.ShowError(fmt.Errorf("limits must be whole numbers of at least 1"), u.window)
+					return
+				}
+				numbers[i] = n
+			}
+
+			selected := make(map[string]bool)
+			for _, label := range kinds.Selected {
+				selected[label] = true
+			}
+			var muted []string
+			for _, kind := range config.NotifyKinds {
+				if !selected[notifyLabels[kind]] {
+					muted = append(muted, kind)
+				}
+			}
+
+			if err := u.config.UpdateSettings(func(s *config.AppSettings) {
+				s.Notifications = config.Notifications{
+					Muted:           muted,
+					QuietFrom:       from,
+					QuietUntil:      until,
+					MaxPerHour:      numbers[0],
+					ErrorsPerMinute: numbers[1],
+					ErrorMinutes:    numbers[2],
+				}
+			}); err != nil {
+				dialog.ShowError(err, u.window)
+				return
+			}
+			u.updateStatus("Notification settings saved")
+		}, u.window)
+}
@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"shinobi-webserver/internal/server"
+)
+
+// The servers map is shared by the UI, the refresh ticker and work done on
+// other goroutines, so it is only used through these helpers
+
+// serverFor returns the site's server, or nil if it has none
+func (u *UI) serverFor(id string) *server.Server {
+	u.serversMu.Lock()
+	defer u.serversMu.Unlock()
+	return u.servers[id]
+}
+
+func (u *UI) setServer(id string, srv *server.Server) {
+	u.serversMu.Lock()
+	defer u.serversMu.Unlock()
+	u.servers[id] = srv
+}
+
+func (u *UI) dropServer(id string) {
+	u.serversMu.Lock()
+	defer u.serversMu.Unlock()
+	delete(u.servers, id)
+}
+
+// allServers returns a copy of the servers by site ID, safe to range over
+func (u *UI) allServers() map[string]*server.Server {
+	u.serversMu.Lock()
+	defer u.serversMu.Unlock()
+	servers := make(map[string]*server.Server, len(u.servers))
+	for id, srv := range u.servers {
+		servers[id] = srv
+	}
+	return servers
+}
+
+// isRunning reports whether the site's server is serving
+func (u *UI) isRunning(id string) bool {
+	srv := u.serverFor(id)
+	return srv != nil && srv.IsRunning()
+}
+
+// serialize runs work that starts off the main goroutine, such as crash
+// handling, one piece at a time
+func (u *UI) serialize(work func()) {
+	u.workMu.Lock()
+	defer u.workMu.Unlock()
+	work()
+}
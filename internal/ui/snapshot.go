@@ -0,0 +1,171 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"shinobi-webserver/internal/snapshot"
+)
+
+// showSnapshots lists a site's snapshots with diff, restore and delete
+// actions, and takes new ones
+func (u *UI) showSnapshots(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+	workspace := u.config.Workspace().Name
+
+	snaps, err := snapshot.List(workspace, *site)
+	if err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	var d dialog.Dialog
+	reopen := func() {
+		d.Hide()
+		u.showSnapshots(id)
+	}
+
+	noteEntry := widget.NewEntry()
+	noteEntry.SetPlaceHolder("Note (optional)")
+	take := widget.NewButton("Take Snapshot", func() {
+		snap, err := snapshot.Create(*site, workspace, strings.TrimSpace(noteEntry.Text), false)
+		if err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		u.updateStatus(fmt.Sprintf("Snapshot of '%s' saved (%d files, %s)", name, snap.Files, formatSize(snap.Archive)))
+		reopen()
+	})
+
+	rows := container.NewVBox()
+	if len(snaps) == 0 {
+		rows.Add(widget.NewLabel("No snapshots yet."))
+	}
+	for _, snap := range snaps {
+		snap := snap
+
+		title := snap.Created.Local().Format("2006-01-02 15:04:05")
+		if snap.Auto {
+			title += " (automatic)"
+		}
+		label := widget.NewLabel(fmt.Sprintf("%s\n%d files, %s (%s compressed)%s",
+			title, snap.Files, formatSize(snap.Size), formatSize(snap.Archive), noteLine(snap.Note)))
+		label.Wrapping = fyne.TextWrapWord
+
+		diff := widget.NewButton("Diff", func() {
+			u.showSnapshotDiff(id, snap)
+		})
+		restore := widget.NewButton("Restore", func() {
+			dialog.ShowConfirm("Restore Snapshot",
+				fmt.Sprintf("Replace the files and settings of '%s' with the snapshot from %s?\n\nFiles added since are deleted. A snapshot of the current state is taken first.", name, title),
+				func(ok bool) {
+					if !ok {
+						return
+					}
+					if _, err := snapshot.Create(*site, workspace, "Before restoring "+snap.ID, true); err != nil {
+						dialog.ShowError(fmt.Errorf("couldn't snapshot the current state: %v", err), u.window)
+						return
+					}
+					if err := snap.Restore(u.config, id); err != nil {
+						dialog.ShowError(err, u.window)
+						return
+					}
+					u.restartSite(id)
+					u.refreshSiteList()
+					u.updateStatus(fmt.Sprintf("Restored '%s' to %s", name, title))
+					reopen()
+				}, u.window)
+		})
+		remove := widget.NewButton("Delete", func() {
+			if err := snap.Remove(); err != nil {
+				dialog.ShowError(err, u.window)
+				return
+			}
+			reopen()
+		})
+
+		rows.Add(container.NewBorder(nil, nil, nil, container.NewHBox(diff, restore, remove), label))
+		rows.Add(widget.NewSeparator())
+	}
+
+	scroll := container.NewVScroll(rows)
+	scroll.SetMinSize(fyne.NewSize(560, 320))
+
+	content := container.NewBorder(container.NewBorder(nil, nil, nil, take, noteEntry), nil, nil, nil, scroll)
+	d = dialog.NewCustom("Snapshots of "+name, "Close", content, u.window)
+	d.Show()
+}
+
+func noteLine(note string) string {
+	if note == "" {
+		return ""
+	}
+	return "\n" + note
+}
+
+func (u *UI) showSnapshotDiff(id string, snap *snapshot.Snapshot) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+
+	diff, err := snap.Diff(site.FolderPath())
+	if err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	var b strings.Builder
+	if diff.Empty() {
+		b.WriteString("The folder matches the snapshot.")
+	}
+	for _, section := range []struct {
+		title string
+		mark  string
+		files []string
+	}{
+		{"Added since", "+", diff.Added},
+		{"Changed since", "~", diff.Modified},
+		{"Deleted since", "-", diff.Removed},
+	} {
+		if len(section.files) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s (%d):\n", section.title, len(section.files))
+		for _, f := range section.files {
+			fmt.Fprintf(&b, "  %s %s\n", section.mark, f)
+		}
+		b.WriteString("\n")
+	}
+
+	text := widget.NewLabel(strings.TrimSpace(b.String()))
+	text.TextStyle = fyne.TextStyle{Monospace: true}
+	scroll := container.NewVScroll(text)
+	scroll.SetMinSize(fyne.NewSize(520, 300))
+	dialog.ShowCustom("Changes since "+snap.Created.Local().Format("2006-01-02 15:04"), "Close", scroll, u.window)
+}
+
+// autoSnapshot saves a site before a bulk change when the setting is on.
+// The change should not go ahead if it returns an error.
+func (u *UI) autoSnapshot(id, reason string) error {
+	if !u.config.Settings().AutoSnapshot {
+		return nil
+	}
+	site := u.config.GetSite(id)
+	if site == nil {
+		return nil
+	}
+	if _, err := snapshot.Create(*site, u.config.Workspace().Name, reason, true); err != nil {
+		return fmt.Errorf("automatic snapshot of '%s' failed: %v", site.Name, err)
+	}
+	return nil
+}
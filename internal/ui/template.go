@@ -0,0 +1,85 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"shinobi-webserver/internal/scaffold"
+)
+
+// templateSelect lists the available templates by name; the returned
+// function looks the selection up
+func (u *UI) templateSelect() (*widget.Select, func() *scaffold.Template) {
+	templates, err := scaffold.List()
+	if err != nil {
+		u.updateStatus(fmt.Sprintf("Failed to read templates: %v", err))
+	}
+
+	var names []string
+	for _, t := range templates {
+		names = append(names, templateLabel(t))
+	}
+
+	sel := widget.NewSelect(names, nil)
+	selected := func() *scaffold.Template {
+		if sel.SelectedIndex() < 0 {
+			return nil
+		}
+		return templates[sel.SelectedIndex()]
+	}
+	return sel, selected
+}
+
+func templateLabel(t *scaffold.Template) string {
+	if t.Description == "" {
+		return t.Name
+	}
+	return t.Name + " – " + t.Description
+}
+
+// applyTemplate adds a template's files to an existing site, keeping any
+// files it already has
+func (u *UI) applyTemplate(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+
+	sel, selected := u.templateSelect()
+	sel.SetSelectedIndex(0)
+
+	dialog.ShowForm("Apply Template to "+name, "Apply", "Cancel",
+		[]*widget.FormItem{
+			{Text: "Template", Widget: sel, HintText: "Existing files are kept"},
+		},
+		func(ok bool) {
+			t := selected()
+			if !ok || t == nil {
+				return
+			}
+			if err := u.autoSnapshot(id, fmt.Sprintf("Before applying template '%s'", t.Name)); err != nil {
+				dialog.ShowError(err, u.window)
+				return
+			}
+			result, err := t.Apply(*site)
+			if err != nil {
+				dialog.ShowError(err, u.window)
+				return
+			}
+			u.updateStatus(fmt.Sprintf("Applied template '%s' to '%s'", t.Name, name))
+			u.showTemplateResult(t, result)
+		}, u.window)
+}
+
+func (u *UI) showTemplateResult(t *scaffold.Template, result *scaffold.Result) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Template '%s' added %d file(s).", t.Name, len(result.Written))
+	if len(result.Skipped) > 0 {
+		fmt.Fprintf(&b, "\n\nKept %d existing file(s):\n%s", len(result.Skipped), strings.Join(result.Skipped, "\n"))
+	}
+	dialog.ShowInformation("Template Applied", b.String(), u.window)
+}
@@ -0,0 +1,221 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"shinobi-webserver/internal/config"
+	"shinobi-webserver/internal/trash"
+)
+
+const (
+	deleteToTrash  = "Move files to the trash"
+	deleteKeepFile = "Keep files, only remove from the list"
+)
+
+// deleteSite removes a site from the list and, unless asked not to, moves
+// its folder to the trash. Folders outside the managed sites folders or
+// under version control need an extra confirmation.
+func (u *UI) deleteSite(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+	folder := site.FolderPath()
+
+	_, err := os.Stat(folder)
+	hasFolder := err == nil
+	roots := []string{config.SitesDir()}
+	for _, ws := range u.config.AllWorkspaces() {
+		roots = append(roots, ws.SitesDir())
+	}
+	risks := trash.Risks(folder, roots)
+	protected := trash.Protected(folder)
+
+	mode := widget.NewRadioGroup([]string{deleteToTrash, deleteKeepFile}, nil)
+	mode.Required = true
+	// Only offer to delete what the app created
+	if hasFolder && len(risks) == 0 && protected == "" {
+		mode.SetSelected(deleteToTrash)
+	} else {
+		mode.SetSelected(deleteKeepFile)
+	}
+	if !hasFolder || protected != "" {
+		mode.Disable()
+	}
+
+	confirm := widget.NewCheck("Delete it anyway", nil)
+	warning := widget.NewLabel("")
+	warning.Wrapping = fyne.TextWrapWord
+	switch {
+	case !hasFolder:
+		warning.SetText(fmt.Sprintf("%s no longer exists.", folder))
+	case protected != "":
+		warning.SetText("The folder can't be deleted: " + protected + ".")
+	case len(risks) > 0:
+		warning.SetText("⚠ " + strings.Join(risks, "\n⚠ "))
+	}
+	if !hasFolder || protected != "" || len(risks) == 0 {
+		confirm.Hide()
+	}
+	if warning.Text == "" {
+		warning.Hide()
+	}
+
+	info := widget.NewLabel(fmt.Sprintf("Remove '%s' from the site list?\nThe server is stopped if it is running.\n\nFolder: %s", name, folder))
+	info.Wrapping = fyne.TextWrapWord
+
+	content := container.NewVBox(info, mode, warning, confirm)
+
+	d := dialog.NewCustomConfirm("Delete Site", "Delete", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		toTrash := mode.Selected == deleteToTrash
+		if toTrash && len(risks) > 0 && !confirm.Checked {
+			dialog.ShowError(fmt.Errorf("tick \"Delete it anyway\" to move this folder to the trash"), u.window)
+			return
+		}
+		u.removeSite(id, toTrash)
+	}, u.window)
+	d.Resize(fyne.NewSize(520, 0))
+	d.Show()
+}
+
+func (u *UI) removeSite(id string, toTrash bool) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+
+	if srv := u.serverFor(id); srv != nil {
+		srv.Stop()
+		u.dropServer(id)
+	}
+
+	// Leave the list first, so a failure never leaves a site pointing at
+	// a folder that went to the trash
+	workspace := u.config.WorkspaceOf(id)
+	if err := u.config.RemoveSite(id); err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	if toTrash {
+		if _, err := trash.Move(*site, workspace); errors.Is(err, trash.ErrLeftovers) {
+			// The site is safe in the trash; only clean-up is left to do
+			u.refreshSiteList()
+			dialog.ShowError(err, u.window)
+			return
+		} else if err != nil {
+			if _, regErr := u.config.RegisterSiteIn(workspace, *site); regErr != nil {
+				err = fmt.Errorf("%v; the site couldn't be put back in the list: %v", err, regErr)
+			}
+			u.refreshSiteList()
+			dialog.ShowError(err, u.window)
+			return
+		}
+	}
+
+	u.refreshSiteList()
+	if toTrash {
+		u.updateStatus(fmt.Sprintf("Site '%s' moved to the trash", name))
+	} else {
+		u.updateStatus(fmt.Sprintf("Site '%s' removed; its files were kept", name))
+	}
+}
+
+// showTrash lists deleted sites with restore and delete actions
+func (u *UI) showTrash() {
+	retention := u.config.Settings().TrashRetention()
+	items, err := trash.List()
+	if err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	var d dialog.Dialog
+	rows := container.NewVBox()
+	if len(items) == 0 {
+		rows.Add(widget.NewLabel("The trash is empty."))
+	}
+
+	for _, it := range items {
+		it := it
+		label := widget.NewLabel(fmt.Sprintf("%s (%s, %s)\nDeleted %s, removed after %s\n%s",
+			it.Site.Name, it.Workspace, formatSize(it.Size),
+			it.Deleted.Local().Format("2006-01-02 15:04"),
+			it.Expires(retention).Local().Format("2006-01-02"),
+			it.Folder))
+		label.Wrapping = fyne.TextWrapWord
+
+		restore := widget.NewButton("Restore", func() {
+			site, err := trash.Restore(u.config, it.ID)
+			if err != nil {
+				dialog.ShowError(err, u.window)
+				return
+			}
+			d.Hide()
+			u.refreshWorkspaces()
+			u.refreshSiteList()
+			u.updateStatus(fmt.Sprintf("Restored '%s' on port %d", site.Name, site.Port))
+		})
+		remove := widget.NewButton("Delete Now", func() {
+			dialog.ShowConfirm("Delete Permanently",
+				fmt.Sprintf("Permanently delete the files of '%s'?", it.Site.Name),
+				func(ok bool) {
+					if !ok {
+						return
+					}
+					if err := trash.Remove(it.ID); err != nil {
+						dialog.ShowError(err, u.window)
+						return
+					}
+					d.Hide()
+					u.showTrash()
+				}, u.window)
+		})
+
+		rows.Add(container.NewBorder(nil, nil, nil, container.NewHBox(restore, remove), label))
+		rows.Add(widget.NewSeparator())
+	}
+
+	scroll := container.NewVScroll(rows)
+	scroll.SetMinSize(fyne.NewSize(560, 320))
+
+	d = dialog.NewCustom("Trash", "Close", scroll, u.window)
+	d.Show()
+}
+
+// purgeTrash drops deleted sites that are past the retention period
+func (u *UI) purgeTrash() {
+	n, err := trash.Purge(u.config.Settings().TrashRetention())
+	if err != nil {
+		u.updateStatus(fmt.Sprintf("Failed to empty expired trash: %v", err))
+		return
+	}
+	if n > 0 {
+		u.updateStatus(fmt.Sprintf("Removed %d expired site(s) from the trash", n))
+	}
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
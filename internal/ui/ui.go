@@ -1,24 +1,37 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/phayes/freeport"
 
+	"shinobi-webserver/assets"
+	"shinobi-webserver/internal/autostart"
+	"shinobi-webserver/internal/checker"
 	"shinobi-webserver/internal/config"
 	"shinobi-webserver/internal/editor"
+	"shinobi-webserver/internal/export"
+	"shinobi-webserver/internal/har"
+	"shinobi-webserver/internal/instance"
+	"shinobi-webserver/internal/netsim"
+	"shinobi-webserver/internal/notify"
+	"shinobi-webserver/internal/scaffold"
 	"shinobi-webserver/internal/server"
+	"shinobi-webserver/internal/siteconfig"
 	"shinobi-webserver/internal/tray"
 )
 
@@ -37,6 +50,7 @@ type SiteWidget struct {
 	logsBtn     *widget.Button
 	editBtn     *widget.Button
 	deleteBtn   *widget.Button
+	moreBtn     *widget.Button
 }
 
 func NewSiteWidget(site *config.Site, ui *UI, isRunning bool) *SiteWidget {
@@ -64,20 +78,24 @@ func (s *SiteWidget) createWidgets() {
 
 	// Create buttons
 	s.startBtn = widget.NewButtonWithIcon("", theme.MediaPlayIcon(), func() {
-		s.ui.startSite(s.site.Name)
+		s.ui.startSite(s.site.ID)
 	})
 	s.stopBtn = widget.NewButtonWithIcon("", theme.MediaStopIcon(), func() {
-		s.ui.stopSite(s.site.Name)
+		s.ui.stopSite(s.site.ID)
 	})
 	s.logsBtn = widget.NewButtonWithIcon("", theme.DocumentIcon(), func() {
-		s.ui.showLogs(s.site.Name)
+		s.ui.showLogs(s.site.ID)
 	})
 	s.editBtn = widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {
-		s.ui.editSite(s.site.Name)
+		s.ui.editSite(s.site.ID)
 	})
 	s.deleteBtn = widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
-		s.ui.deleteSite(s.site.Name)
+		s.ui.deleteSite(s.site.ID)
 	})
+	s.moreBtn = widget.NewButtonWithIcon("", theme.MoreVerticalIcon(), nil)
+	s.moreBtn.OnTapped = func() {
+		s.ui.showSiteMenu(s.site.ID, s.moreBtn)
+	}
 }
 
 func (s *SiteWidget) updateButtons() {
@@ -116,6 +134,7 @@ func (s *SiteWidget) CreateRenderer() fyne.WidgetRenderer {
 		s.logsBtn,
 		s.editBtn,
 		s.deleteBtn,
+		s.moreBtn,
 	)
 
 	// Main container
@@ -140,22 +159,49 @@ func (s *SiteWidget) Update(site *config.Site, isRunning bool) {
 }
 
 type UI struct {
-	app          fyne.App
-	window       fyne.Window
-	config       *config.Config
-	servers      map[string]*server.Server
-	siteList     *widget.List
-	statusBar    *widget.Label
-	tray         *tray.Tray
-	refreshTimer *time.Timer
+	app     fyne.App
+	window  fyne.Window
+	config  *config.Config
+	servers map[string]*server.Server
+	// Guards servers; see serverFor
+	serversMu sync.Mutex
+	// Held by serialize
+	workMu   sync.Mutex
+	siteList *widget.List
+
+	workspaceSelect *widget.Select
+	statusBar       *widget.Label
+	tray            *tray.Tray
+	refreshTimer    *time.Timer
+	stopWatch       func()
+
+	notifier *notify.Notifier
+	spikes   *notify.Spikes
+	// Automatic restarts of crashed servers, by site ID; used under
+	// serialize
+	restarts map[string][]time.Time
+}
+
+// Options carries startup state from main into the UI
+type Options struct {
+	// LoadError is what config.Load returned, shown as a repair dialog
+	LoadError error
+	// Background keeps the window hidden; it is opened from the tray
+	Background bool
+	// Instance takes requests from later launches; nil if the lock
+	// couldn't be checked
+	Instance *instance.Instance
+	// What this launch asked for, one of the instance actions
+	Action     string
+	ActionArgs []string
 }
 
 func Start(cfg *config.Config) {
 	a := app.New()
-	StartWithApp(a, cfg)
+	StartWithApp(a, cfg, Options{})
 }
 
-func StartWithApp(a fyne.App, cfg *config.Config) {
+func StartWithApp(a fyne.App, cfg *config.Config, opts Options) {
 	ui := &UI{
 		app:     a,
 		config:  cfg,
@@ -171,43 +217,94 @@ func StartWithApp(a fyne.App, cfg *config.Config) {
 	// Initialize tray
 	ui.initTray()
 
+	ui.initNotifications()
+
 	// Build UI
 	ui.buildUI()
 
 	// Start refresh timer
 	ui.startAutoRefresh()
 
+	// Pick up edits made to the config file outside the app
+	ui.watchConfig()
+
+	ui.purgeTrash()
+
+	// Bring back the sites that were running at exit or start on launch
+	ui.launchSites()
+
+	if opts.LoadError != nil {
+		ui.showRepairDialog(opts.LoadError)
+	}
+
+	// Dropping a folder onto the window adds it as a site
+	ui.window.SetOnDropped(ui.onDropped)
+
+	if opts.Instance != nil {
+		defer opts.Instance.Close()
+		if err := opts.Instance.Listen(ui.handleRequest); err != nil {
+			ui.updateStatus(fmt.Sprintf("Other launches can't reach this window: %v", err))
+		}
+	}
+	if opts.Action != "" && opts.Action != instance.ActionShow {
+		if err := ui.handleRequest(opts.Action, opts.ActionArgs); err != nil {
+			dialog.ShowError(err, ui.window)
+		}
+	}
+
 	// Handle window close
 	ui.window.SetCloseIntercept(func() {
 		ui.window.Hide()
 	})
 
-	ui.window.ShowAndRun()
+	if opts.Background && ui.tray.Available() && opts.LoadError == nil {
+		ui.app.Run()
+	} else {
+		ui.window.ShowAndRun()
+	}
+	ui.shutdown()
 }
 
 func (u *UI) setAppIcon() {
-	// Try to load icon from various locations
-	iconPaths := []string{
-		"assets/icons/icon.png",
-		"icon.png",
-		"./icon.png",
-	}
-
-	for _, path := range iconPaths {
-		if _, err := os.Stat(path); err == nil {
-			// For now, use theme icon
-			u.app.SetIcon(theme.FyneLogo())
-			break
-		}
-	}
+	u.app.SetIcon(assets.Icon)
 }
 
 func (u *UI) initTray() {
-	u.tray = tray.NewTray(u.app, func() {
-		u.window.Show()
+	u.tray = tray.NewTray(u.app, tray.Actions{
+		Show: func() {
+			u.window.Show()
+			u.window.RequestFocus()
+		},
+		Start:    u.startSite,
+		Stop:     u.stopSite,
+		Open:     u.openSite,
+		CopyURL:  u.copySiteURL,
+		StartAll: u.startAll,
+		StopAll:  u.stopAll,
 	})
 }
 
+// updateTray shows the sites of the current workspace in the tray menu
+func (u *UI) updateTray() {
+	if u.tray == nil {
+		return
+	}
+	var sites []tray.Site
+	for _, site := range u.config.Sites() {
+		srv := u.serverFor(site.ID)
+		running := srv != nil && srv.IsRunning()
+		sites = append(sites, tray.Site{
+			ID:      site.ID,
+			Name:    site.Name,
+			Port:    site.Port,
+			Running: running,
+			// Started by us and not stopped by the user, yet not serving
+			Failed: site.Running && !running,
+		})
+	}
+	u.tray.Update(sites)
+}
+
 func (u *UI) buildUI() {
 	// Create toolbar
 	toolbar := widget.NewToolbar(
@@ -229,7 +326,7 @@ func (u *UI) buildUI() {
 	// Create site list using custom SiteWidget
 	u.siteList = widget.NewList(
 		func() int {
-			return len(u.config.Sites)
+			return len(u.config.Sites())
 		},
 		func() fyne.CanvasObject {
 			// Create a dummy site for template
@@ -237,12 +334,13 @@ func (u *UI) buildUI() {
 			return NewSiteWidget(dummySite, u, false)
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			if id < 0 || id >= len(u.config.Sites) {
+			sites := u.config.Sites()
+			if id < 0 || id >= len(sites) {
 				return
 			}
 
-			site := &u.config.Sites[id]
-			isRunning := u.servers[site.Name] != nil && u.servers[site.Name].Running
+			site := &sites[id]
+			isRunning := u.isRunning(site.ID)
 
 			siteWidget := obj.(*SiteWidget)
 			siteWidget.Update(site, isRunning)
@@ -255,7 +353,7 @@ func (u *UI) buildUI() {
 
 	// Create main layout
 	content := container.NewBorder(
-		toolbar,
+		container.NewVBox(toolbar, u.buildWorkspaceBar()),
 		container.NewVBox(
 			widget.NewSeparator(),
 			u.statusBar,
@@ -273,24 +371,38 @@ func (u *UI) showAddSiteDialog() {
 	nameEntry.SetPlaceHolder("my-awesome-site")
 
 	portEntry := widget.NewEntry()
-	port, err := freeport.GetFreePort()
+	port, err := u.config.GetAvailablePort()
 	if err != nil {
-		// Fallback to config's auto port
-		port, _ = u.config.GetAvailablePort()
+		// Range used up, any free port will do
+		port, _ = freeport.GetFreePort()
 	}
 	portEntry.SetText(strconv.Itoa(port))
 
+	sitesDir := u.config.Workspace().SitesDir()
 	folderEntry := widget.NewEntry()
-	folderEntry.SetPlaceHolder("sites/site-name")
-	folderEntry.SetText("sites/")
+	folderEntry.SetPlaceHolder(filepath.Join(sitesDir, "site-name"))
+	folderEntry.SetText(sitesDir + string(filepath.Separator))
 
 	entryFileEntry := widget.NewEntry()
 	entryFileEntry.SetText("index.html")
 
+	templateSelect, selectedTemplate := u.templateSelect()
+	templateSelect.OnChanged = func(string) {
+		// Follow the template's entry file unless the user typed their own
+		if t := selectedTemplate(); t != nil && (entryFileEntry.Text == "" || entryFileEntry.Text == "index.html" || entryFileEntry.Text == "index.md") {
+			entryFileEntry.SetText(t.Entry())
+		}
+	}
+	for i, option := range templateSelect.Options {
+		if strings.HasPrefix(option, scaffold.Default+" ") {
+			templateSelect.SetSelectedIndex(i)
+		}
+	}
+
 	// Auto-update folder based on name
 	nameEntry.OnChanged = func(text string) {
 		if text != "" {
-			folderEntry.SetText(filepath.Join("sites", strings.ToLower(strings.ReplaceAll(text, " ", "-"))))
+			folderEntry.SetText(filepath.Join(sitesDir, strings.ToLower(strings.ReplaceAll(text, " ", "-"))))
 		}
 	}
 
@@ -300,6 +412,7 @@ func (u *UI) showAddSiteDialog() {
 			{Text: "Port", Widget: portEntry},
 			{Text: "Folder", Widget: folderEntry},
 			{Text: "Entry File", Widget: entryFileEntry},
+			{Text: "Template", Widget: templateSelect, HintText: "Files already in the folder are kept"},
 		},
 		func(ok bool) {
 			if ok && nameEntry.Text != "" {
@@ -322,57 +435,98 @@ func (u *UI) showAddSiteDialog() {
 					EntryFile: entryFileEntry.Text,
 				}
 
-				if err := u.config.AddSite(site); err != nil {
+				id, err := u.config.AddSite(site)
+				if err != nil {
 					dialog.ShowError(err, u.window)
 					return
 				}
-
 				u.refreshSiteList()
+
+				created := u.config.GetSite(id)
+				if created == nil {
+					created = &site
+				}
+				kept := ""
+				if t := selectedTemplate(); t != nil {
+					result, err := t.Apply(*created)
+					if err != nil {
+						dialog.ShowError(fmt.Errorf("site created, but applying template '%s' failed: %v", t.Name, err), u.window)
+						return
+					}
+					if len(result.Skipped) > 0 {
+						kept = fmt.Sprintf("\nKept %d existing file(s)", len(result.Skipped))
+					}
+				}
+
 				u.updateStatus(fmt.Sprintf("Site '%s' created successfully!", site.Name))
 				dialog.ShowInformation("Success",
-					fmt.Sprintf("Site created successfully!\n\nFolder: %s\nPort: %d\nEntry File: %s",
-						site.Folder, site.Port, site.EntryFile), u.window)
+					fmt.Sprintf("Site created successfully!\n\nFolder: %s\nPort: %d\nEntry File: %s%s",
+						created.FolderPath(), site.Port, site.EntryFile, kept), u.window)
 			}
 		}, u.window)
 }
 
-func (u *UI) startSite(name string) {
-	site := u.config.GetSite(name)
+func (u *UI) startSite(id string) {
+	site := u.config.GetSite(id)
 	if site == nil {
 		return
 	}
+	name := site.Name
 
-	srv, exists := u.servers[name]
-	if exists && srv.Running {
+	if srv := u.serverFor(id); srv != nil && srv.IsRunning() {
 		u.updateStatus(fmt.Sprintf("Site '%s' is already running", name))
 		return
 	}
 
-	if !exists {
-		srv = server.New(site.Port, site.Folder)
-		u.servers[name] = srv
-	}
-
 	u.updateStatus(fmt.Sprintf("Starting site '%s' on port %d...", name, site.Port))
 
-	if err := srv.Start(); err != nil {
+	if err := u.startServer(site); err != nil {
+		u.notifyFailedStart(site, err)
 		dialog.ShowError(err, u.window)
 		u.updateStatus(fmt.Sprintf("Failed to start site '%s': %v", name, err))
-		delete(u.servers, name)
 		return
 	}
 
-	// Update site's last started time
-	site.LastStarted = time.Now()
-	u.config.UpdateSite(name, *site)
-
 	u.refreshSiteList()
 	u.updateStatus(fmt.Sprintf("Site '%s' started on http://localhost:%d", name, site.Port))
 }
 
-func (u *UI) stopSite(name string) {
-	srv, exists := u.servers[name]
-	if !exists || !srv.Running {
+// startServer starts the site's server, creating it if needed, and
+// remembers the site as running
+func (u *UI) startServer(site *config.Site) error {
+	srv := u.serverFor(site.ID)
+	if srv == nil {
+		var err error
+		srv, err = server.NewForSite(site, u.config.Settings().NetworkProfiles)
+		if err != nil {
+			return err
+		}
+		id := site.ID
+		srv.OnCrash = func(err error) { u.serverCrashed(id, err) }
+		u.setServer(site.ID, srv)
+	}
+
+	if err := srv.Start(); err != nil {
+		u.dropServer(site.ID)
+		return err
+	}
+
+	// Update site's last started time
+	site.LastStarted = time.Now()
+	site.Running = true
+	if err := u.config.SetRunning(site.ID, true, site.LastStarted); err != nil {
+		// The server is up; only remembering it failed
+		dialog.ShowError(fmt.Errorf("site '%s' started, but saving that failed: %v", site.Name, err), u.window)
+	}
+	return nil
+}
+
+func (u *UI) stopSite(id string) {
+	name := u.siteName(id)
+	srv := u.serverFor(id)
+	if srv == nil || !srv.IsRunning() {
+		// A server that died is stopped now, so it's no longer an error
+		u.clearFailed(id)
 		u.updateStatus(fmt.Sprintf("Site '%s' is not running", name))
 		return
 	}
@@ -385,12 +539,51 @@ func (u *UI) stopSite(name string) {
 		return
 	}
 
+	if err := u.config.SetRunning(id, false, time.Time{}); err != nil {
+		dialog.ShowError(fmt.Errorf("site '%s' stopped, but saving that failed: %v", name, err), u.window)
+	}
+
 	u.refreshSiteList()
 	u.updateStatus(fmt.Sprintf("Site '%s' stopped", name))
 }
 
-func (u *UI) openSite(name string) {
-	site := u.config.GetSite(name)
+// clearFailed forgets that a site whose server died should be running
+func (u *UI) clearFailed(id string) {
+	if site := u.config.GetSite(id); site != nil && site.Running {
+		if err := u.config.SetRunning(id, false, time.Time{}); err != nil {
+			dialog.ShowError(err, u.window)
+		}
+		u.refreshSiteList()
+	}
+}
+
+// siteName labels a site in messages, falling back to its ID
+func (u *UI) siteName(id string) string {
+	if site := u.config.GetSite(id); site != nil {
+		return site.Name
+	}
+	return id
+}
+
+// restartSite recreates a site's server so it picks up changed settings,
+// starting it again if it was running
+func (u *UI) restartSite(id string) {
+	srv := u.serverFor(id)
+	if srv == nil {
+		return
+	}
+	running := srv.IsRunning()
+	if running {
+		srv.Stop()
+	}
+	u.dropServer(id)
+	if running {
+		u.startSite(id)
+	}
+}
+
+func (u *UI) openSite(id string) {
+	site := u.config.GetSite(id)
 	if site == nil {
 		return
 	}
@@ -406,13 +599,25 @@ func (u *UI) openSite(name string) {
 	u.updateStatus(fmt.Sprintf("Opened %s in browser", url))
 }
 
-func (u *UI) showLogs(name string) {
-	site := u.config.GetSite(name)
+func (u *UI) copySiteURL(id string) {
+	site := u.config.GetSite(id)
 	if site == nil {
 		return
 	}
+	url := fmt.Sprintf("http://localhost:%d", site.Port)
+	u.window.Clipboard().SetContent(url)
+	u.updateStatus(fmt.Sprintf("Copied %s", url))
+}
 
-	logsDir := filepath.Join(site.Folder, "logs")
+func (u *UI) showLogs(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+
+	logsDir := site.LogsPath()
+	os.MkdirAll(logsDir, 0755)
 	if err := editor.OpenFolder(logsDir); err != nil {
 		dialog.ShowError(fmt.Errorf("failed to open logs folder: %v", err), u.window)
 		return
@@ -421,66 +626,581 @@ func (u *UI) showLogs(name string) {
 	u.updateStatus(fmt.Sprintf("Opened logs folder for '%s'", name))
 }
 
-func (u *UI) editSite(name string) {
-	site := u.config.GetSite(name)
+func (u *UI) showSiteMenu(id string, anchor fyne.CanvasObject) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+
+	srv := u.serverFor(id)
+	running := srv != nil && srv.IsRunning()
+
+	openItem := fyne.NewMenuItem("Open in Browser", func() {
+		u.openSite(id)
+	})
+	openItem.Disabled = !running
+
+	var recordItem *fyne.MenuItem
+	if running && srv.IsRecording() {
+		recordItem = fyne.NewMenuItem("Stop HAR Recording", func() {
+			u.stopRecording(id)
+		})
+	} else {
+		recordItem = fyne.NewMenuItem("Start HAR Recording", func() {
+			u.startRecording(id)
+		})
+		recordItem.Disabled = !running
+	}
+
+	exportItem := fyne.NewMenuItem("Export HAR...", func() {
+		u.exportHAR(id)
+	})
+	exportItem.Disabled = srv == nil
+
+	clearItem := fyne.NewMenuItem("Clear HAR Mock", func() {
+		u.clearHARMock(id)
+	})
+	clearItem.Disabled = site.HARMock == ""
+
+	markdownItem := fyne.NewMenuItem("Render Markdown", func() {
+		u.toggleMarkdown(id)
+	})
+	markdownItem.Checked = site.Markdown
+
+	templatesItem := fyne.NewMenuItem("Process Templates", func() {
+		u.toggleTemplates(id)
+	})
+	templatesItem.Checked = site.Templates
+
+	autoStartItem := fyne.NewMenuItem("Start on Launch", func() {
+		u.toggleAutoStart(id)
+	})
+	autoStartItem.Checked = site.AutoStart
+
+	checkItem := fyne.NewMenuItem("Check Site...", func() {
+		u.checkSite(id)
+	})
+	checkItem.Disabled = !running
+
+	projectFile := siteconfig.Find(site.FolderPath())
+	projectItem := fyne.NewMenuItem("Edit Project File", func() {
+		if err := editor.OpenFile(projectFile); err != nil {
+			dialog.ShowError(err, u.window)
+		}
+	})
+	projectItem.Disabled = projectFile == ""
+
+	networkItem := fyne.NewMenuItem("Network", nil)
+	networkItem.ChildMenu = u.networkMenu(id, site.NetworkProfile)
+
+	menu := fyne.NewMenu("",
+		openItem,
+		fyne.NewMenuItem("Open Folder", func() {
+			u.openSiteFolder(id)
+		}),
+		fyne.NewMenuItem("Duplicate Site...", func() {
+			u.duplicateSite(id)
+		}),
+		fyne.NewMenuItemSeparator(),
+		markdownItem,
+		templatesItem,
+		networkItem,
+		autoStartItem,
+		fyne.NewMenuItemSeparator(),
+		checkItem,
+		fyne.NewMenuItem("Export Static Site...", func() {
+			u.exportSite(id, false)
+		}),
+		fyne.NewMenuItem("Export Static Site as ZIP...", func() {
+			u.exportSite(id, true)
+		}),
+		fyne.NewMenuItem("Export Site Definition...", func() {
+			u.exportSites([]string{id}, false)
+		}),
+		fyne.NewMenuItem("Save shinobi.json to Folder", func() {
+			u.saveProjectFile(id)
+		}),
+		projectItem,
+		fyne.NewMenuItem("Apply Template...", func() {
+			u.applyTemplate(id)
+		}),
+		fyne.NewMenuItem("Snapshots...", func() {
+			u.showSnapshots(id)
+		}),
+		fyne.NewMenuItemSeparator(),
+		recordItem,
+		exportItem,
+		fyne.NewMenuItem("Load HAR Mock...", func() {
+			u.loadHARMock(id)
+		}),
+		clearItem,
+	)
+
+	c := fyne.CurrentApp().Driver().CanvasForObject(anchor)
+	pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(anchor)
+	widget.ShowPopUpMenuAtPosition(menu, c, pos.Add(fyne.NewPos(0, anchor.Size().Height)))
+}
+
+func (u *UI) toggleMarkdown(id string) {
+	site := u.config.GetSite(id)
 	if site == nil {
 		return
 	}
+	name := site.Name
 
-	if err := editor.OpenFolder(site.Folder); err != nil {
-		dialog.ShowError(fmt.Errorf("failed to open folder: %v", err), u.window)
+	site.Markdown = !site.Markdown
+	if srv := u.serverFor(id); srv != nil {
+		srv.SetMarkdown(site.Markdown, site.MarkdownLayout)
+	}
+
+	if err := u.config.UpdateSite(id, *site); err != nil {
+		dialog.ShowError(err, u.window)
 		return
 	}
 
-	u.updateStatus(fmt.Sprintf("Opened folder for editing: %s", site.Folder))
+	if site.Markdown {
+		u.updateStatus(fmt.Sprintf("Markdown rendering enabled for '%s'", name))
+	} else {
+		u.updateStatus(fmt.Sprintf("Markdown rendering disabled for '%s'", name))
+	}
 }
 
-func (u *UI) deleteSite(name string) {
-	dialog.ShowConfirm("Delete Site",
-		fmt.Sprintf("Are you sure you want to delete '%s'?\n\nThis will:\n• Stop the server if running\n• Delete all site files\n• Remove from configuration", name),
-		func(ok bool) {
-			if ok {
-				// Stop server if running
-				if srv, exists := u.servers[name]; exists {
-					srv.Stop()
-					delete(u.servers, name)
-				}
+func (u *UI) toggleTemplates(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
 
-				// Get site info before deletion
-				site := u.config.GetSite(name)
-				if site != nil {
-					// Remove files
-					if err := os.RemoveAll(site.Folder); err != nil {
-						dialog.ShowError(fmt.Errorf("failed to remove files: %v", err), u.window)
-						return
-					}
+	site.Templates = !site.Templates
+	if srv := u.serverFor(id); srv != nil {
+		srv.SetTemplates(site.Templates)
+	}
 
-					// Remove from config
-					if err := u.config.RemoveSite(name); err != nil {
-						dialog.ShowError(err, u.window)
-						return
-					}
+	if err := u.config.UpdateSite(id, *site); err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	if site.Templates {
+		u.updateStatus(fmt.Sprintf("Template processing enabled for '%s'", name))
+	} else {
+		u.updateStatus(fmt.Sprintf("Template processing disabled for '%s'", name))
+	}
+}
+
+func (u *UI) toggleAutoStart(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+
+	site.AutoStart = !site.AutoStart
+	if err := u.config.UpdateSite(id, *site); err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	if site.AutoStart {
+		u.updateStatus(fmt.Sprintf("'%s' will start when the app launches", site.Name))
+	} else {
+		u.updateStatus(fmt.Sprintf("'%s' will no longer start on launch", site.Name))
+	}
+}
+
+func (u *UI) networkMenu(id, current string) *fyne.Menu {
+	offItem := fyne.NewMenuItem("No Throttling", func() {
+		u.setNetworkProfile(id, "")
+	})
+	offItem.Checked = current == ""
+
+	items := []*fyne.MenuItem{offItem, fyne.NewMenuItemSeparator()}
+	for _, p := range u.config.NetworkProfiles() {
+		profileName := p.Name
+		item := fyne.NewMenuItem(profileName, func() {
+			u.setNetworkProfile(id, profileName)
+		})
+		item.Checked = strings.EqualFold(profileName, current)
+		items = append(items, item)
+	}
+
+	return fyne.NewMenu("", items...)
+}
+
+func (u *UI) setNetworkProfile(id, profileName string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+
+	profile := netsim.Find(profileName, u.config.Settings().NetworkProfiles)
+	if profileName != "" && profile == nil {
+		dialog.ShowError(fmt.Errorf("unknown network profile %q", profileName), u.window)
+		return
+	}
+
+	// Applies immediately to a running server
+	if srv := u.serverFor(id); srv != nil {
+		srv.SetNetworkProfile(profile)
+	}
+
+	site.NetworkProfile = profileName
+	if err := u.config.UpdateSite(id, *site); err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	if profile == nil {
+		u.updateStatus(fmt.Sprintf("Network simulation disabled for '%s'", name))
+	} else {
+		u.updateStatus(fmt.Sprintf("Network profile for '%s' set to %s", name, profile.Name))
+	}
+}
+
+func (u *UI) checkSite(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+
+	srv := u.serverFor(id)
+	if srv == nil || !srv.IsRunning() {
+		u.updateStatus(fmt.Sprintf("Site '%s' is not running", name))
+		return
+	}
+
+	u.updateStatus(fmt.Sprintf("Checking '%s'...", name))
+	go func() {
+		baseURL := fmt.Sprintf("http://localhost:%d", site.Port)
+		report, err := checker.Check(baseURL, site.FolderPath(), site.EntryFile, checker.Options{})
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("check failed: %v", err), u.window)
+			return
+		}
+		u.showCheckReport(name, report)
+		u.updateStatus(fmt.Sprintf("Checked %d URLs for '%s'", report.Checked, name))
+	}()
+}
 
-					u.refreshSiteList()
-					u.updateStatus(fmt.Sprintf("Site '%s' deleted", name))
+func (u *UI) showCheckReport(name string, report *checker.Report) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Checked %d URLs (%d external references skipped)\n\n", report.Checked, report.External)
+
+	if len(report.Issues) == 0 {
+		b.WriteString("No problems found.")
+	}
+
+	kinds := []struct {
+		kind  checker.Kind
+		title string
+	}{
+		{checker.KindBroken, "Broken"},
+		{checker.KindMixedContent, "Mixed content"},
+		{checker.KindRedirect, "Redirects"},
+		{checker.KindOversized, "Oversized assets"},
+		{checker.KindOrphan, "Orphaned files"},
+	}
+	for _, k := range kinds {
+		if report.Count(k.kind) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s (%d):\n", k.title, report.Count(k.kind))
+		for _, issue := range report.Issues {
+			if issue.Kind != k.kind {
+				continue
+			}
+			line := "• " + issue.URL
+			if issue.Source != "" {
+				line += " ← " + issue.Source
+			}
+			if issue.Status != 0 {
+				line += fmt.Sprintf(" (%d)", issue.Status)
+			}
+			if issue.Detail != "" {
+				line += " – " + issue.Detail
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	text := widget.NewLabel(b.String())
+	text.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(text)
+	scroll.SetMinSize(fyne.NewSize(600, 400))
+
+	dialog.ShowCustom(fmt.Sprintf("Check Results – %s", name), "Close", scroll, u.window)
+}
+
+func (u *UI) exportSite(id string, asZip bool) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+
+	exportTo := func(target string) {
+		u.updateStatus(fmt.Sprintf("Exporting '%s'...", name))
+		go func() {
+			srv, err := server.NewForSite(site, u.config.Settings().NetworkProfiles)
+			if err != nil {
+				dialog.ShowError(err, u.window)
+				return
+			}
+
+			// Writing over an existing folder was confirmed already
+			report, err := export.Export(srv.Handler(), site.FolderPath(), target, true)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("export failed: %v", err), u.window)
+				return
+			}
+			u.showExportReport(report)
+			u.updateStatus(fmt.Sprintf("Exported '%s' to %s", name, target))
+		}()
+	}
+	run := func(target string) {
+		if !export.TargetNotEmpty(target) {
+			exportTo(target)
+			return
+		}
+		dialog.ShowConfirm("Folder Not Empty",
+			fmt.Sprintf("%s already has files in it.\nExported files will replace those with the same name. Continue?", target),
+			func(ok bool) {
+				if ok {
+					exportTo(target)
 				}
+			}, u.window)
+	}
+
+	if asZip {
+		save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, u.window)
+				return
 			}
-		},
-		u.window,
-	)
+			if writer == nil {
+				return
+			}
+			target := writer.URI().Path()
+			writer.Close()
+			run(target)
+		}, u.window)
+		save.SetFileName(name + ".zip")
+		save.Show()
+		return
+	}
+
+	dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		if dir == nil {
+			return
+		}
+		run(dir.Path())
+	}, u.window)
+}
+
+func (u *UI) showExportReport(report *export.Report) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Exported %d files to %s\n", len(report.Files), report.Target)
+	if len(report.Redirects) > 0 {
+		fmt.Fprintf(&b, "Wrote %d redirects to %s\n", len(report.Redirects), export.RedirectsFile)
+	}
+
+	if len(report.BrokenLinks) == 0 {
+		b.WriteString("\nNo broken internal links.")
+	} else {
+		fmt.Fprintf(&b, "\n%d broken internal links:\n", len(report.BrokenLinks))
+		for _, l := range report.BrokenLinks {
+			fmt.Fprintf(&b, "• %s → %s (%d)\n", l.Page, l.Link, l.Status)
+		}
+	}
+	if len(report.Errors) > 0 {
+		fmt.Fprintf(&b, "\n%d pages couldn't be exported:\n", len(report.Errors))
+		for _, f := range report.Errors {
+			fmt.Fprintf(&b, "• %s: %s\n", f.URL, f.Error)
+		}
+	}
+
+	text := widget.NewLabel(b.String())
+	text.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(text)
+	scroll.SetMinSize(fyne.NewSize(500, 300))
+
+	dialog.ShowCustom("Export Complete", "Close", scroll, u.window)
+}
+
+func (u *UI) startRecording(id string) {
+	name := u.siteName(id)
+	srv := u.serverFor(id)
+	if srv == nil || !srv.IsRunning() {
+		u.updateStatus(fmt.Sprintf("Site '%s' is not running", name))
+		return
+	}
+
+	srv.StartRecording()
+	u.updateStatus(fmt.Sprintf("Recording traffic for '%s'", name))
+}
+
+func (u *UI) stopRecording(id string) {
+	name := u.siteName(id)
+	srv := u.serverFor(id)
+	if srv == nil {
+		return
+	}
+
+	h := srv.StopRecording()
+	if h == nil {
+		return
+	}
+	u.updateStatus(fmt.Sprintf("Recorded %d requests for '%s'", len(h.Log.Entries), name))
+}
+
+func (u *UI) exportHAR(id string) {
+	name := u.siteName(id)
+	srv := u.serverFor(id)
+	if srv == nil {
+		return
+	}
+
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		path := writer.URI().Path()
+		writer.Close()
+
+		if err := srv.ExportHAR(path); err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		u.updateStatus(fmt.Sprintf("Exported HAR to %s", path))
+	}, u.window)
+	save.SetFileName(name + ".har")
+	save.Show()
+}
+
+func (u *UI) loadHARMock(id string) {
+	open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		path := reader.URI().Path()
+		reader.Close()
+
+		matchBody := widget.NewCheck("Match request bodies", nil)
+		dialog.ShowCustomConfirm("Load HAR Mock", "Load", "Cancel",
+			container.NewVBox(widget.NewLabel(path), matchBody),
+			func(ok bool) {
+				if ok {
+					u.applyHARMock(id, path, matchBody.Checked)
+				}
+			}, u.window)
+	}, u.window)
+	open.SetFilter(storage.NewExtensionFileFilter([]string{".har", ".json"}))
+	open.Show()
+}
+
+func (u *UI) applyHARMock(id, path string, matchBody bool) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+
+	// Validate the file up front, even when the site isn't running yet
+	if _, err := har.Load(path); err != nil {
+		dialog.ShowError(fmt.Errorf("failed to load HAR: %v", err), u.window)
+		return
+	}
+
+	if srv := u.serverFor(id); srv != nil {
+		if err := srv.LoadHAR(path, matchBody); err != nil {
+			dialog.ShowError(err, u.window)
+			return
+		}
+	}
+
+	site.HARMock = path
+	site.HARMatchBody = matchBody
+	if err := u.config.UpdateSite(id, *site); err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	u.updateStatus(fmt.Sprintf("Serving HAR mock for '%s'", name))
+}
+
+func (u *UI) clearHARMock(id string) {
+	site := u.config.GetSite(id)
+	if site == nil {
+		return
+	}
+	name := site.Name
+
+	if srv := u.serverFor(id); srv != nil {
+		srv.ClearHAR()
+	}
+
+	site.HARMock = ""
+	site.HARMatchBody = false
+	if err := u.config.UpdateSite(id, *site); err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	u.updateStatus(fmt.Sprintf("HAR mock cleared for '%s'", name))
 }
 
 func (u *UI) showSettingsDialog() {
+	ws := u.config.Workspace()
+
 	minPortEntry := widget.NewEntry()
-	minPortEntry.SetText(strconv.Itoa(u.config.AppSettings.AutoPortMin))
+	minPortEntry.SetText(strconv.Itoa(ws.AutoPortMin))
 
 	maxPortEntry := widget.NewEntry()
-	maxPortEntry.SetText(strconv.Itoa(u.config.AppSettings.AutoPortMax))
+	maxPortEntry.SetText(strconv.Itoa(ws.AutoPortMax))
+
+	trashDaysEntry := widget.NewEntry()
+	trashDaysEntry.SetText(strconv.Itoa(int(u.config.Settings().TrashRetention().Hours() / 24)))
+
+	autoSnapshotCheck := widget.NewCheck("Before imports and templates", nil)
+	autoSnapshotCheck.SetChecked(u.config.Settings().AutoSnapshot)
 
-	dialog.ShowForm("Settings", "Save", "Cancel",
+	restartCheck := widget.NewCheck("Restart servers that stop on their own", nil)
+	restartCheck.SetChecked(u.config.Settings().RestartCrashed)
+
+	notificationsBtn := widget.NewButton("Choose...", u.showNotificationSettings)
+
+	loginCheck := widget.NewCheck("Start in the tray when I log in", nil)
+	loginCheck.SetChecked(autostart.Enabled())
+	loginHint := ""
+	if !autostart.Supported() {
+		loginCheck.Disable()
+		loginHint = "Only available on Linux"
+	}
+
+	dialog.ShowForm(fmt.Sprintf("Settings (%s)", ws.Name), "Save", "Cancel",
 		[]*widget.FormItem{
 			{Text: "Minimum Auto Port", Widget: minPortEntry},
 			{Text: "Maximum Auto Port", Widget: maxPortEntry},
+			{Text: "Keep Deleted Sites (days)", Widget: trashDaysEntry},
+			{Text: "Automatic Snapshots", Widget: autoSnapshotCheck},
+			{Text: "Crashed Servers", Widget: restartCheck},
+			{Text: "Notifications", Widget: notificationsBtn},
+			{Text: "Launch at Login", Widget: loginCheck, HintText: loginHint},
 		},
 		func(ok bool) {
 			if ok {
@@ -491,20 +1211,53 @@ func (u *UI) showSettingsDialog() {
 					dialog.ShowError(fmt.Errorf("invalid port range"), u.window)
 					return
 				}
+				trashDays, err := strconv.Atoi(trashDaysEntry.Text)
+				if err != nil || trashDays < 1 {
+					dialog.ShowError(fmt.Errorf("days to keep deleted sites must be at least 1"), u.window)
+					return
+				}
 
-				u.config.AppSettings.AutoPortMin = minPort
-				u.config.AppSettings.AutoPortMax = maxPort
+				updated := *ws
+				updated.AutoPortMin = minPort
+				updated.AutoPortMax = maxPort
 
-				if err := u.config.Save(); err != nil {
+				if err := u.config.UpdateWorkspace(ws.Name, updated); err != nil {
 					dialog.ShowError(err, u.window)
 					return
 				}
+				if err := u.config.UpdateSettings(func(s *config.AppSettings) {
+					s.TrashDays = trashDays
+					s.AutoSnapshot = autoSnapshotCheck.Checked
+					s.RestartCrashed = restartCheck.Checked
+				}); err != nil {
+					dialog.ShowError(err, u.window)
+					return
+				}
+				if loginCheck.Checked != autostart.Enabled() {
+					if err := u.setLaunchAtLogin(loginCheck.Checked); err != nil {
+						dialog.ShowError(fmt.Errorf("failed to change launch at login: %v", err), u.window)
+						return
+					}
+				}
 
 				u.updateStatus("Settings saved")
 			}
 		}, u.window)
 }
 
+// setLaunchAtLogin adds or removes the login entry, which starts the app
+// with the config file in use now
+func (u *UI) setLaunchAtLogin(enabled bool) error {
+	if !enabled {
+		return autostart.Disable()
+	}
+	var args []string
+	if config.Path() != config.DefaultPath() {
+		args = append(args, "--config", config.Path())
+	}
+	return autostart.Enable(args...)
+}
+
 func (u *UI) showHelpDialog() {
 	helpText := `Shinobi Web Server - Help
 
@@ -519,7 +1272,7 @@ Usage:
 1. Click '+' to add a new site
 2. Click '▶' to start a server
 3. Click '⬛' to stop a server
-4. Click '📄' to edit site files
+4. Click '📄' to edit a site's settings
 5. Click '📋' to view logs
 
 Keyboard Shortcuts:
@@ -527,7 +1280,8 @@ Keyboard Shortcuts:
 • Ctrl+R: Refresh List
 • Ctrl+Q: Quit
 
-Logs are stored in: sites/[site-name]/logs/`
+Logs are stored in: [site folder]/logs/
+Configuration: ` + config.Path()
 
 	dialog.ShowInformation("Help", helpText, u.window)
 }
@@ -536,6 +1290,7 @@ func (u *UI) refreshSiteList() {
 	if u.siteList != nil {
 		u.siteList.Refresh()
 	}
+	u.updateTray()
 }
 
 func (u *UI) updateStatus(message string) {
@@ -564,20 +1319,146 @@ func (u *UI) startAutoRefresh() {
 			select {
 			case <-ticker.C:
 				u.refreshSiteList()
+				u.checkErrorSpikes()
 			}
 		}
 	}()
 }
 
+func (u *UI) showRepairDialog(loadErr error) {
+	var invalid *config.ValidationError
+	if errors.As(loadErr, &invalid) && !invalid.HasErrors() {
+		// Warnings only, everything still works
+		u.updateStatus(fmt.Sprintf("Config has %d warnings, see %s", len(invalid.Problems), config.Path()))
+		return
+	}
+
+	var b strings.Builder
+	if invalid != nil {
+		b.WriteString("Problems were found in the configuration:\n\n")
+		for _, p := range invalid.Problems {
+			fmt.Fprintf(&b, "• [%s] %s\n", p.Severity, p)
+		}
+	} else {
+		fmt.Fprintf(&b, "The configuration could not be loaded:\n\n%v\n\nThe file has been left untouched.", loadErr)
+	}
+
+	text := widget.NewLabel(b.String())
+	text.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(text)
+	scroll.SetMinSize(fyne.NewSize(560, 260))
+
+	var d dialog.Dialog
+	buttons := container.NewHBox(
+		widget.NewButton("Open Config File", func() {
+			// The watcher reloads the file once it is fixed
+			if err := editor.OpenFile(config.Path()); err != nil {
+				dialog.ShowError(err, u.window)
+			}
+		}),
+	)
+
+	if invalid != nil {
+		buttons.Add(widget.NewButton("Remove Broken Sites", func() {
+			d.Hide()
+			u.removeBrokenSites()
+		}))
+		buttons.Add(widget.NewButton("Continue Anyway", func() {
+			d.Hide()
+		}))
+	} else {
+		buttons.Add(widget.NewButton("Start Fresh", func() {
+			d.Hide()
+			u.startFresh()
+		}))
+		buttons.Add(widget.NewButton("Quit", func() {
+			u.app.Quit()
+		}))
+	}
+
+	d = dialog.NewCustomWithoutButtons("Configuration Problems", container.NewBorder(nil, buttons, nil, nil, scroll), u.window)
+	d.Show()
+}
+
+func (u *UI) removeBrokenSites() {
+	names, err := u.config.RemoveBrokenSites()
+	if err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	u.refreshSiteList()
+	u.updateStatus(fmt.Sprintf("Removed %d broken sites: %s", len(names), strings.Join(names, ", ")))
+}
+
+func (u *UI) startFresh() {
+	aside, err := config.MoveAside()
+	if err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	u.config = config.NewDefault()
+	if err := u.config.Save(); err != nil {
+		dialog.ShowError(err, u.window)
+		return
+	}
+
+	// The watcher belongs to the config that was replaced
+	if u.stopWatch != nil {
+		u.stopWatch()
+	}
+	u.watchConfig()
+
+	u.refreshWorkspaces()
+	u.refreshSiteList()
+	dialog.ShowInformation("Configuration Reset",
+		fmt.Sprintf("The old configuration was kept as:\n%s", aside), u.window)
+}
+
+func (u *UI) watchConfig() {
+	stop, err := u.config.Watch(func(err error) {
+		if err != nil {
+			u.updateStatus(fmt.Sprintf("Ignored external config change: %v", err))
+			return
+		}
+		u.refreshWorkspaces()
+		u.refreshSiteList()
+		u.updateStatus("Configuration reloaded from disk")
+	})
+	if err != nil {
+		u.updateStatus(fmt.Sprintf("Not watching config file: %v", err))
+		return
+	}
+	u.stopWatch = stop
+}
+
+// shutdown remembers which sites were running for the next launch, then
+// stops them
+func (u *UI) shutdown() {
+	running := make(map[string]bool)
+	for id, srv := range u.allServers() {
+		running[id] = srv.IsRunning()
+	}
+	if err := u.config.SaveRunning(running); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to save running sites: %v\n", err)
+	}
+	u.cleanup()
+}
+
 func (u *UI) cleanup() {
 	// Stop all servers
-	for name, server := range u.servers {
+	for id, server := range u.allServers() {
 		server.Stop()
-		delete(u.servers, name)
+		u.dropServer(id)
 	}
 
 	// Stop refresh timer
 	if u.refreshTimer != nil {
 		u.refreshTimer.Stop()
 	}
+
+	if u.stopWatch != nil {
+		u.stopWatch()
+	}
 }
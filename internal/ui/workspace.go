@@ -0,0 +1,205 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"shinobi-webserver/internal/config"
+)
+
+func (u *UI) buildWorkspaceBar() fyne.CanvasObject {
+	u.workspaceSelect = widget.NewSelect(nil, func(name string) {
+		if name != "" && name != u.config.Workspace().Name {
+			u.switchWorkspace(name)
+		}
+	})
+	u.refreshWorkspaces()
+
+	var menuBtn *widget.Button
+	menuBtn = widget.NewButtonWithIcon("", theme.MoreVerticalIcon(), func() {
+		u.showWorkspaceMenu(menuBtn)
+	})
+
+	buttons := container.NewHBox(
+		widget.NewButtonWithIcon("Start All", theme.MediaPlayIcon(), u.startAll),
+		widget.NewButtonWithIcon("Stop All", theme.MediaStopIcon(), u.stopAll),
+		menuBtn,
+	)
+
+	return container.NewBorder(nil, nil, widget.NewLabel("Workspace:"), buttons, u.workspaceSelect)
+}
+
+// refreshWorkspaces syncs the switcher with the config
+func (u *UI) refreshWorkspaces() {
+	if u.workspaceSelect == nil {
+		return
+	}
+	u.workspaceSelect.Options = u.config.WorkspaceNames()
+	u.workspaceSelect.SetSelected(u.config.Workspace().Name)
+	u.workspaceSelect.Refresh()
+}
+
+func (u *UI) showWorkspaceMenu(anchor fyne.CanvasObject) {
+	current := u.config.Workspace().Name
+
+	menu := fyne.NewMenu("",
+		fyne.NewMenuItem("New Workspace...", func() {
+			u.showWorkspaceDialog(nil)
+		}),
+		fyne.NewMenuItem("Edit Workspace...", func() {
+			u.showWorkspaceDialog(u.config.Workspace())
+		}),
+		fyne.NewMenuItem("Remove Workspace", func() {
+			u.removeWorkspace(current)
+		}),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Open Folder as Site...", u.openFolderAsSite),
+		fyne.NewMenuItem("Import Sites...", u.importSites),
+		fyne.NewMenuItem("Trash...", u.showTrash),
+		fyne.NewMenuItem("Export Sites...", func() {
+			u.exportSites(nil, false)
+		}),
+		fyne.NewMenuItem("Export Sites with Content...", func() {
+			u.exportSites(nil, true)
+		}),
+	)
+
+	canvas := fyne.CurrentApp().Driver().CanvasForObject(anchor)
+	pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(anchor)
+	widget.ShowPopUpMenuAtPosition(menu, canvas, pos.Add(fyne.NewPos(0, anchor.Size().Height)))
+}
+
+// showWorkspaceDialog creates a workspace, or edits ws when it is not nil
+func (u *UI) showWorkspaceDialog(ws *config.Workspace) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("client-project")
+
+	folderEntry := widget.NewEntry()
+	folderEntry.SetPlaceHolder(config.SitesDir())
+
+	minPortEntry := widget.NewEntry()
+	minPortEntry.SetText("8000")
+	maxPortEntry := widget.NewEntry()
+	maxPortEntry.SetText("9000")
+
+	title, confirm := "New Workspace", "Create"
+	if ws != nil {
+		title, confirm = "Edit Workspace", "Save"
+		nameEntry.SetText(ws.Name)
+		if ws.Folder != "" {
+			folderEntry.SetText(ws.Base())
+		}
+		minPortEntry.SetText(strconv.Itoa(ws.AutoPortMin))
+		maxPortEntry.SetText(strconv.Itoa(ws.AutoPortMax))
+	}
+
+	dialog.ShowForm(title, confirm, "Cancel",
+		[]*widget.FormItem{
+			{Text: "Name", Widget: nameEntry},
+			{Text: "Base Folder", Widget: folderEntry, HintText: "Leave empty to use the default sites folder"},
+			{Text: "Minimum Auto Port", Widget: minPortEntry},
+			{Text: "Maximum Auto Port", Widget: maxPortEntry},
+		},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+
+			minPort, err1 := strconv.Atoi(minPortEntry.Text)
+			maxPort, err2 := strconv.Atoi(maxPortEntry.Text)
+			if err1 != nil || err2 != nil || minPort >= maxPort {
+				dialog.ShowError(fmt.Errorf("invalid port range"), u.window)
+				return
+			}
+
+			updated := config.Workspace{
+				Name:        nameEntry.Text,
+				Folder:      folderEntry.Text,
+				AutoPortMin: minPort,
+				AutoPortMax: maxPort,
+			}
+
+			if ws == nil {
+				if err := u.config.AddWorkspace(updated); err != nil {
+					dialog.ShowError(err, u.window)
+					return
+				}
+				u.switchWorkspace(updated.Name)
+				return
+			}
+
+			if err := u.config.UpdateWorkspace(ws.Name, updated); err != nil {
+				dialog.ShowError(err, u.window)
+				return
+			}
+			u.refreshWorkspaces()
+			u.refreshSiteList()
+			u.updateStatus(fmt.Sprintf("Workspace '%s' saved", updated.Name))
+		}, u.window)
+}
+
+func (u *UI) removeWorkspace(name string) {
+	dialog.ShowConfirm("Remove Workspace",
+		fmt.Sprintf("Remove the workspace '%s'?\n\nOnly empty workspaces can be removed.", name),
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := u.config.RemoveWorkspace(name); err != nil {
+				dialog.ShowError(err, u.window)
+				return
+			}
+			u.refreshWorkspaces()
+			u.refreshSiteList()
+			u.updateStatus(fmt.Sprintf("Workspace '%s' removed", name))
+		}, u.window)
+}
+
+func (u *UI) switchWorkspace(name string) {
+	if err := u.config.SwitchWorkspace(name); err != nil {
+		dialog.ShowError(err, u.window)
+		u.refreshWorkspaces()
+		return
+	}
+
+	u.refreshWorkspaces()
+	u.refreshSiteList()
+	u.updateStatus(fmt.Sprintf("Switched to workspace '%s'", u.config.Workspace().Name))
+}
+
+// startAll starts every stopped site of the current workspace
+func (u *UI) startAll() {
+	var ids []string
+	for _, site := range u.config.Sites() {
+		if srv := u.serverFor(site.ID); srv == nil || !srv.IsRunning() {
+			ids = append(ids, site.ID)
+		}
+	}
+
+	started := 0
+	for _, id := range ids {
+		u.startSite(id)
+		if srv := u.serverFor(id); srv != nil && srv.IsRunning() {
+			started++
+		}
+	}
+	u.updateStatus(fmt.Sprintf("Started %d of %d sites in '%s'", started, len(ids), u.config.Workspace().Name))
+}
+
+// stopAll stops the running sites of the current workspace
+func (u *UI) stopAll() {
+	count := 0
+	for _, site := range u.config.Sites() {
+		if srv := u.serverFor(site.ID); srv != nil && srv.IsRunning() {
+			u.stopSite(site.ID)
+			count++
+		}
+	}
+	u.updateStatus(fmt.Sprintf("Stopped %d sites in '%s'", count, u.config.Workspace().Name))
+}